@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PluginHealth is the health of a plugin, as seen by the operator.
+// +kubebuilder:validation:Enum=Healthy;Unhealthy;Disconnected
+type PluginHealth string
+
+const (
+	// PluginHealthy is the health of a connected plugin serving calls. Plugins serving the
+	// gRPC health service (grpc.health.v1.Health) must also report SERVING.
+	PluginHealthy PluginHealth = "Healthy"
+	// PluginUnhealthy is the health of a connected plugin whose health check fails.
+	PluginUnhealthy PluginHealth = "Unhealthy"
+	// PluginDisconnected is the health of a plugin with no stream to the operator.
+	PluginDisconnected PluginHealth = "Disconnected"
+)
+
+// PluginRegistrationStatus is the state of a plugin connected to the operator.
+type PluginRegistrationStatus struct {
+	// Version is the version announced by the plugin when it registered.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Replicas is the number of streams of the plugin to the operator.
+	Replicas int32 `json:"replicas"`
+
+	// ConnectedSince is the time the plugin connected, while it stays connected.
+	// +optional
+	ConnectedSince *metav1.Time `json:"connectedSince,omitempty"`
+
+	// DisconnectedSince is the time the last stream of the plugin was found closed.
+	// The object is deleted when the plugin does not come back for a while.
+	// +optional
+	DisconnectedSince *metav1.Time `json:"disconnectedSince,omitempty"`
+
+	// LastHeartbeat is the last time the operator heard from the plugin.
+	// +optional
+	LastHeartbeat *metav1.Time `json:"lastHeartbeat,omitempty"`
+
+	// Methods are the full names of the gRPC methods advertised by the plugin
+	// ("/package.Service/Method").
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+
+	// Health is the health of the plugin.
+	// +optional
+	Health PluginHealth `json:"health,omitempty"`
+
+	// Message explains the health of the plugin, when it is not healthy.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.version`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.replicas`
+// +kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PluginRegistration reflects a plugin connected to an operator. It is maintained by the
+// operator (see the registration package) and named after the plugin.
+type PluginRegistration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status PluginRegistrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PluginRegistrationList contains a list of PluginRegistration.
+type PluginRegistrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PluginRegistration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PluginRegistration{}, &PluginRegistrationList{})
+}
@@ -46,7 +46,9 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -61,6 +63,11 @@ type connectionConfig struct {
 	addr          string
 	name          string
 	tokenProvider token.TokenProvider
+	streamOpts    []stream.Option
+
+	// Reconnection of the plugin stream, enabled by default
+	noReconnect     bool
+	reconnectPolicy stream.ReconnectPolicy
 }
 
 // ClientOption is a functional option for connection configuration
@@ -97,8 +104,41 @@ func WithStaticToken(tokenS string) ClientOption {
 	}
 }
 
+// WithStreamOptions sets options for the underlying PluginStreamClient,
+// such as stream.WithMaxConcurrentCalls.
+func WithStreamOptions(opts ...stream.Option) ClientOption {
+	return func(c *connectionConfig) {
+		c.streamOpts = append(c.streamOpts, opts...)
+	}
+}
+
+// WithService makes the plugin serve another gRPC service on the same connection,
+// besides the one given to New. Calls are routed by full method name.
+func WithService(serviceDesc *grpc.ServiceDesc, impl any) ClientOption {
+	return func(c *connectionConfig) {
+		c.streamOpts = append(c.streamOpts, stream.WithService(serviceDesc, impl))
+	}
+}
+
+// WithReconnectPolicy sets how the plugin reconnects when its stream to the operator fails.
+// See stream.ReconnectPolicy for the defaults.
+func WithReconnectPolicy(policy stream.ReconnectPolicy) ClientOption {
+	return func(c *connectionConfig) {
+		c.noReconnect = false
+		c.reconnectPolicy = policy
+	}
+}
+
+// WithoutReconnect makes HandleRPCCalls return when the stream to the operator fails,
+// instead of opening a new one.
+func WithoutReconnect() ClientOption {
+	return func(c *connectionConfig) {
+		c.noReconnect = true
+	}
+}
+
 type Client struct {
-	stream.PluginStreamClient
+	*stream.PluginStreamClient
 
 	conn *grpc.ClientConn
 }
@@ -120,13 +160,17 @@ type Client struct {
 //
 // The client automatically connects to the PluginFrameworkService.PluginStream method
 // for bidirectional communication, eliminating the need for manual stream creation.
+// When the stream fails, HandleRPCCalls opens a new one on the same connection and
+// registers the plugin again (see WithReconnectPolicy and WithoutReconnect).
 //
 // Parameters:
 //   - ctx: context for the connection (used for cancellation)
 //   - name: plugin name (used for registration)
 //   - addr: server address (unix:///path/to/socket or https://host:port)
 //   - pluginVersion: plugin version string (sent during registration)
-//   - serviceDesc: gRPC service descriptor for RPC routing
+//   - serviceDesc: gRPC service descriptor for RPC routing (see WithService for more services),
+//     or an empty descriptor with a nil impl to register services with generated
+//     Register*Server functions on the returned Client instead
 //   - impl: service implementation (handles incoming RPC calls)
 //   - opts: client options for authentication and configuration
 //
@@ -184,18 +228,52 @@ func New(
 		return nil, fmt.Errorf("failed to create plugin stream: %w", err)
 	}
 
+	// Reconnect through the same connection, unless the stream options say otherwise
+	streamOpts := conn.streamOpts
+	if !conn.noReconnect {
+		dial := func(ctx context.Context) (stream.StreamInterface, error) {
+			return frameworkClient.PluginStream(ctx)
+		}
+		streamOpts = append([]stream.Option{stream.WithReconnect(dial, conn.reconnectPolicy)}, streamOpts...)
+	}
+
 	// Create and return the plugin stream client
-	pluginStreamClient, err := stream.NewPluginStreamClient(ctx, grpcStream, name, pluginVersion, serviceDesc, impl)
+	pluginStreamClient, err := stream.NewPluginStreamClient(ctx, grpcStream, name, pluginVersion, serviceDesc, impl, streamOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create plugin stream client: %w", err)
 	}
 
 	return &Client{
-		PluginStreamClient: *pluginStreamClient,
+		PluginStreamClient: pluginStreamClient,
 		conn:               grpcConn,
 	}, nil
 }
 
+// ErrNotSubprocess is returned by NewSubprocess when the plugin was not launched by an operator.
+var ErrNotSubprocess = errors.New("plugin was not launched by an operator: run it through server.WithSubprocessPlugins")
+
+// NewSubprocess connects a plugin executable launched by an operator (see
+// server.WithSubprocessPlugins) back to it, over the private socket and with the name and
+// one-time token found in its environment. It is New for the main function of such plugins:
+//
+//	conn, err := client.NewSubprocess(ctx, "v1.0.0", pb.MyService_ServiceDesc, &myServiceImpl{})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = conn.HandleRPCCalls(ctx)
+//
+// The token is only valid once, so HandleRPCCalls returns when the stream fails instead of
+// reconnecting: the plugin should then exit, and the operator launches it again.
+// It returns ErrNotSubprocess when the plugin was not launched by an operator.
+func NewSubprocess(ctx context.Context, pluginVersion string, serviceDesc grpc.ServiceDesc, impl any, opts ...ClientOption) (*Client, error) {
+	if os.Getenv(stream.SubprocessCookieEnv) != stream.SubprocessCookie {
+		return nil, ErrNotSubprocess
+	}
+
+	opts = append(opts, WithStaticToken(os.Getenv(stream.SubprocessTokenEnv)), WithoutReconnect())
+	return New(ctx, os.Getenv(stream.SubprocessNameEnv), os.Getenv(stream.SubprocessAddrEnv), pluginVersion, serviceDesc, impl, opts...)
+}
+
 // Close closes the underlying gRPC connection.
 // This should be called when the client is shutting down to ensure proper cleanup.
 func (c *Client) Close() error {
@@ -2,13 +2,19 @@ package client
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/status"
 
+	"github.com/guilhem/operator-plugin-framework/server"
+	"github.com/guilhem/operator-plugin-framework/stream"
 	"github.com/guilhem/operator-plugin-framework/token"
 )
 
@@ -163,6 +169,68 @@ func TestWithStaticToken(t *testing.T) {
 	}
 }
 
+// blockingTestService blocks its handler until released.
+type blockingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingTestService) UnaryCall(context.Context, *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return &grpc_testing.SimpleResponse{}, nil
+}
+
+func TestWithStreamOptions(t *testing.T) {
+	addr := fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock"))
+	s := server.New(addr)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- s.Start(ctx) }()
+	defer func() {
+		cancel()
+		<-errs
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	svc := &blockingTestService{started: make(chan struct{}, 1), release: make(chan struct{})}
+	c, err := New(ctx, "limited-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, svc,
+		WithStreamOptions(stream.WithMaxConcurrentCalls(1), stream.WithMaxQueuedCalls(0)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	go func() { _ = c.HandleRPCCalls(ctx) }()
+
+	conn, err := s.PluginConn("limited-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	testClient := grpc_testing.NewTestServiceClient(conn)
+
+	// A call takes the only handler slot
+	done := make(chan error, 1)
+	go func() {
+		_, err := testClient.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+		done <- err
+	}()
+	<-svc.started
+
+	// The concurrency limit given with WithStreamOptions rejects the next one
+	_, err = testClient.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted over the concurrency limit, got %v", err)
+	}
+
+	close(svc.release)
+	if err := <-done; err != nil {
+		t.Errorf("UnaryCall() error = %v", err)
+	}
+}
+
 func TestTokenCredential_GetRequestMetadata(t *testing.T) {
 	provider := token.NewStaticTokenProvider("test-token-123")
 	cred := &token.TokenCredential{Provider: provider}
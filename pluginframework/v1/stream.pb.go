@@ -7,8 +7,10 @@
 package pluginframeworkv1
 
 import (
+	status "google.golang.org/genproto/googleapis/rpc/status"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -31,7 +33,21 @@ type PluginStreamMessage struct {
 	//	*PluginStreamMessage_RpcCall
 	//	*PluginStreamMessage_RpcResponse
 	//	*PluginStreamMessage_Error
-	Payload       isPluginStreamMessage_Payload `protobuf_oneof:"payload"`
+	//	*PluginStreamMessage_Cancel
+	//	*PluginStreamMessage_StreamHeader
+	//	*PluginStreamMessage_StreamFrame
+	//	*PluginStreamMessage_StreamEnd
+	//	*PluginStreamMessage_StreamHalfClose
+	//	*PluginStreamMessage_StreamWindowUpdate
+	//	*PluginStreamMessage_PayloadChunk
+	//	*PluginStreamMessage_RegisterAck
+	//	*PluginStreamMessage_ServicesUpdate
+	//	*PluginStreamMessage_SessionAck
+	//	*PluginStreamMessage_Notification
+	Payload isPluginStreamMessage_Payload `protobuf_oneof:"payload"`
+	// Position of the message among those sent in its direction of a resumable session,
+	// starting at 1. It is 0 when the session is not resumable, and for PluginSessionAck.
+	Sequence      uint64 `protobuf:"varint,14,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -109,58 +125,971 @@ func (x *PluginStreamMessage) GetError() *PluginError {
 	return nil
 }
 
+func (x *PluginStreamMessage) GetCancel() *PluginCancel {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_Cancel); ok {
+			return x.Cancel
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetStreamHeader() *PluginStreamHeader {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_StreamHeader); ok {
+			return x.StreamHeader
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetStreamFrame() *PluginStreamFrame {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_StreamFrame); ok {
+			return x.StreamFrame
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetStreamEnd() *PluginStreamEnd {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_StreamEnd); ok {
+			return x.StreamEnd
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetStreamHalfClose() *PluginStreamHalfClose {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_StreamHalfClose); ok {
+			return x.StreamHalfClose
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetStreamWindowUpdate() *PluginStreamWindowUpdate {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_StreamWindowUpdate); ok {
+			return x.StreamWindowUpdate
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetPayloadChunk() *PluginPayloadChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_PayloadChunk); ok {
+			return x.PayloadChunk
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetRegisterAck() *PluginRegisterAck {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_RegisterAck); ok {
+			return x.RegisterAck
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetServicesUpdate() *PluginServicesUpdate {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_ServicesUpdate); ok {
+			return x.ServicesUpdate
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetSessionAck() *PluginSessionAck {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_SessionAck); ok {
+			return x.SessionAck
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetNotification() *PluginNotification {
+	if x != nil {
+		if x, ok := x.Payload.(*PluginStreamMessage_Notification); ok {
+			return x.Notification
+		}
+	}
+	return nil
+}
+
+func (x *PluginStreamMessage) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
 type isPluginStreamMessage_Payload interface {
 	isPluginStreamMessage_Payload()
 }
 
-type PluginStreamMessage_Register struct {
-	Register *PluginRegister `protobuf:"bytes,1,opt,name=register,proto3,oneof"`
+type PluginStreamMessage_Register struct {
+	Register *PluginRegister `protobuf:"bytes,1,opt,name=register,proto3,oneof"`
+}
+
+type PluginStreamMessage_RpcCall struct {
+	RpcCall *PluginRPCCall `protobuf:"bytes,2,opt,name=rpc_call,json=rpcCall,proto3,oneof"`
+}
+
+type PluginStreamMessage_RpcResponse struct {
+	RpcResponse *PluginRPCResponse `protobuf:"bytes,3,opt,name=rpc_response,json=rpcResponse,proto3,oneof"`
+}
+
+type PluginStreamMessage_Error struct {
+	Error *PluginError `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+}
+
+type PluginStreamMessage_Cancel struct {
+	Cancel *PluginCancel `protobuf:"bytes,5,opt,name=cancel,proto3,oneof"`
+}
+
+type PluginStreamMessage_StreamHeader struct {
+	StreamHeader *PluginStreamHeader `protobuf:"bytes,6,opt,name=stream_header,json=streamHeader,proto3,oneof"`
+}
+
+type PluginStreamMessage_StreamFrame struct {
+	StreamFrame *PluginStreamFrame `protobuf:"bytes,7,opt,name=stream_frame,json=streamFrame,proto3,oneof"`
+}
+
+type PluginStreamMessage_StreamEnd struct {
+	StreamEnd *PluginStreamEnd `protobuf:"bytes,8,opt,name=stream_end,json=streamEnd,proto3,oneof"`
+}
+
+type PluginStreamMessage_StreamHalfClose struct {
+	StreamHalfClose *PluginStreamHalfClose `protobuf:"bytes,9,opt,name=stream_half_close,json=streamHalfClose,proto3,oneof"`
+}
+
+type PluginStreamMessage_StreamWindowUpdate struct {
+	StreamWindowUpdate *PluginStreamWindowUpdate `protobuf:"bytes,10,opt,name=stream_window_update,json=streamWindowUpdate,proto3,oneof"`
+}
+
+type PluginStreamMessage_PayloadChunk struct {
+	PayloadChunk *PluginPayloadChunk `protobuf:"bytes,11,opt,name=payload_chunk,json=payloadChunk,proto3,oneof"`
+}
+
+type PluginStreamMessage_RegisterAck struct {
+	RegisterAck *PluginRegisterAck `protobuf:"bytes,12,opt,name=register_ack,json=registerAck,proto3,oneof"`
+}
+
+type PluginStreamMessage_ServicesUpdate struct {
+	ServicesUpdate *PluginServicesUpdate `protobuf:"bytes,13,opt,name=services_update,json=servicesUpdate,proto3,oneof"`
+}
+
+type PluginStreamMessage_SessionAck struct {
+	SessionAck *PluginSessionAck `protobuf:"bytes,15,opt,name=session_ack,json=sessionAck,proto3,oneof"`
+}
+
+type PluginStreamMessage_Notification struct {
+	Notification *PluginNotification `protobuf:"bytes,16,opt,name=notification,proto3,oneof"`
+}
+
+func (*PluginStreamMessage_Register) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_RpcCall) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_RpcResponse) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_Error) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_Cancel) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_StreamHeader) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_StreamFrame) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_StreamEnd) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_StreamHalfClose) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_StreamWindowUpdate) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_PayloadChunk) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_RegisterAck) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_ServicesUpdate) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_SessionAck) isPluginStreamMessage_Payload() {}
+
+func (*PluginStreamMessage_Notification) isPluginStreamMessage_Payload() {}
+
+// PluginRegister is sent by the plugin when it connects to register itself.
+// Plugins setting protocol_version wait for a PluginRegisterAck before serving calls.
+type PluginRegister struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Name            string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version         string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Compression     []string               `protobuf:"bytes,3,rep,name=compression,proto3" json:"compression,omitempty"`                                 // Payload compression algorithms the plugin accepts (e.g., "gzip", "zstd")
+	ProtocolVersion uint32                 `protobuf:"varint,4,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"` // Version of the stream protocol spoken by the plugin, 0 for legacy plugins
+	Features        []string               `protobuf:"bytes,5,rep,name=features,proto3" json:"features,omitempty"`                                       // Optional protocol features supported by the plugin
+	Methods         []string               `protobuf:"bytes,6,rep,name=methods,proto3" json:"methods,omitempty"`                                         // Full names of the methods served (e.g., "/package.Service/Method")
+	FileDescriptors [][]byte               `protobuf:"bytes,7,rep,name=file_descriptors,json=fileDescriptors,proto3" json:"file_descriptors,omitempty"`  // Serialized FileDescriptorProto of the services and their dependencies, if shared
+	ResumeToken     string                 `protobuf:"bytes,8,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`              // Token of the session to resume, empty for a new session
+	ResumeSequence  uint64                 `protobuf:"varint,9,opt,name=resume_sequence,json=resumeSequence,proto3" json:"resume_sequence,omitempty"`    // Sequence of the last message received in the resumed session
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PluginRegister) Reset() {
+	*x = PluginRegister{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginRegister) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginRegister) ProtoMessage() {}
+
+func (x *PluginRegister) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginRegister.ProtoReflect.Descriptor instead.
+func (*PluginRegister) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PluginRegister) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PluginRegister) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *PluginRegister) GetCompression() []string {
+	if x != nil {
+		return x.Compression
+	}
+	return nil
+}
+
+func (x *PluginRegister) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *PluginRegister) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+func (x *PluginRegister) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *PluginRegister) GetFileDescriptors() [][]byte {
+	if x != nil {
+		return x.FileDescriptors
+	}
+	return nil
+}
+
+func (x *PluginRegister) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+func (x *PluginRegister) GetResumeSequence() uint64 {
+	if x != nil {
+		return x.ResumeSequence
+	}
+	return 0
+}
+
+// PluginRegisterAck is the operator's answer to a PluginRegister.
+// A rejected plugin gets the reason in rejection, and the stream is then closed.
+type PluginRegisterAck struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Rejection         *status.Status         `protobuf:"bytes,1,opt,name=rejection,proto3" json:"rejection,omitempty"`                                            // Why the plugin was rejected, unset when accepted
+	SessionId         string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`                           // Identifies this plugin session on the operator
+	ProtocolVersion   uint32                 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`        // Protocol version used on the stream
+	Features          []string               `protobuf:"bytes,4,rep,name=features,proto3" json:"features,omitempty"`                                              // Features of the plugin accepted by the operator
+	Limits            *PluginServerLimits    `protobuf:"bytes,5,opt,name=limits,proto3" json:"limits,omitempty"`                                                  // Limits the plugin must respect when sending
+	ResumeToken       string                 `protobuf:"bytes,6,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`                     // Token to resume the session on a new stream, empty if not resumable
+	ResumeGracePeriod *durationpb.Duration   `protobuf:"bytes,7,opt,name=resume_grace_period,json=resumeGracePeriod,proto3" json:"resume_grace_period,omitempty"` // How long the session can be resumed after the stream is lost
+	Resumed           bool                   `protobuf:"varint,8,opt,name=resumed,proto3" json:"resumed,omitempty"`                                               // Whether the session of the plugin's resume_token was resumed
+	ResumeSequence    uint64                 `protobuf:"varint,9,opt,name=resume_sequence,json=resumeSequence,proto3" json:"resume_sequence,omitempty"`           // Sequence of the last message received in the resumed session
+	Methods           []string               `protobuf:"bytes,10,rep,name=methods,proto3" json:"methods,omitempty"`                                               // Full names of the methods the operator serves to the plugin
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *PluginRegisterAck) Reset() {
+	*x = PluginRegisterAck{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginRegisterAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginRegisterAck) ProtoMessage() {}
+
+func (x *PluginRegisterAck) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginRegisterAck.ProtoReflect.Descriptor instead.
+func (*PluginRegisterAck) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PluginRegisterAck) GetRejection() *status.Status {
+	if x != nil {
+		return x.Rejection
+	}
+	return nil
+}
+
+func (x *PluginRegisterAck) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *PluginRegisterAck) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *PluginRegisterAck) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+func (x *PluginRegisterAck) GetLimits() *PluginServerLimits {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *PluginRegisterAck) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+func (x *PluginRegisterAck) GetResumeGracePeriod() *durationpb.Duration {
+	if x != nil {
+		return x.ResumeGracePeriod
+	}
+	return nil
+}
+
+func (x *PluginRegisterAck) GetResumed() bool {
+	if x != nil {
+		return x.Resumed
+	}
+	return false
+}
+
+func (x *PluginRegisterAck) GetResumeSequence() uint64 {
+	if x != nil {
+		return x.ResumeSequence
+	}
+	return 0
+}
+
+func (x *PluginRegisterAck) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+// PluginServerLimits are the limits of the operator side of the stream.
+type PluginServerLimits struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	MaxMessageSize uint32                 `protobuf:"varint,1,opt,name=max_message_size,json=maxMessageSize,proto3" json:"max_message_size,omitempty"` // Largest stream message the operator receives, in bytes
+	MaxPayloadSize uint32                 `protobuf:"varint,2,opt,name=max_payload_size,json=maxPayloadSize,proto3" json:"max_payload_size,omitempty"` // Largest payload of a call the operator accepts, in bytes
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PluginServerLimits) Reset() {
+	*x = PluginServerLimits{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginServerLimits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginServerLimits) ProtoMessage() {}
+
+func (x *PluginServerLimits) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginServerLimits.ProtoReflect.Descriptor instead.
+func (*PluginServerLimits) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PluginServerLimits) GetMaxMessageSize() uint32 {
+	if x != nil {
+		return x.MaxMessageSize
+	}
+	return 0
+}
+
+func (x *PluginServerLimits) GetMaxPayloadSize() uint32 {
+	if x != nil {
+		return x.MaxPayloadSize
+	}
+	return 0
+}
+
+// PluginServicesUpdate advertises the services registered by the plugin after its registration.
+// It is only sent when the operator accepted the "service-updates" feature.
+type PluginServicesUpdate struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Methods         []string               `protobuf:"bytes,1,rep,name=methods,proto3" json:"methods,omitempty"`                                        // Full names of the methods added
+	FileDescriptors [][]byte               `protobuf:"bytes,2,rep,name=file_descriptors,json=fileDescriptors,proto3" json:"file_descriptors,omitempty"` // Serialized FileDescriptorProto of the added services, if shared
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PluginServicesUpdate) Reset() {
+	*x = PluginServicesUpdate{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginServicesUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginServicesUpdate) ProtoMessage() {}
+
+func (x *PluginServicesUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginServicesUpdate.ProtoReflect.Descriptor instead.
+func (*PluginServicesUpdate) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PluginServicesUpdate) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *PluginServicesUpdate) GetFileDescriptors() [][]byte {
+	if x != nil {
+		return x.FileDescriptors
+	}
+	return nil
+}
+
+// PluginNotification is sent by the plugin to have the operator reconcile a Kubernetes object,
+// for instance when it notices a change of the external resource behind it.
+// It is only sent when the operator accepted the "notifications" feature.
+type PluginNotification struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Namespace     string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"` // Namespace of the object, empty for cluster-scoped objects
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`           // Name of the object
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`       // Why the object should be reconciled, for logs and authorization
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginNotification) Reset() {
+	*x = PluginNotification{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginNotification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginNotification) ProtoMessage() {}
+
+func (x *PluginNotification) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginNotification.ProtoReflect.Descriptor instead.
+func (*PluginNotification) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PluginNotification) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *PluginNotification) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PluginNotification) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// PluginSessionAck acknowledges the messages received in a resumable session, up to sequence.
+// Acknowledged messages are no longer kept for replay by the sender.
+type PluginSessionAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginSessionAck) Reset() {
+	*x = PluginSessionAck{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginSessionAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginSessionAck) ProtoMessage() {}
+
+func (x *PluginSessionAck) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginSessionAck.ProtoReflect.Descriptor instead.
+func (*PluginSessionAck) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PluginSessionAck) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+// PluginRPCCall represents an RPC method call to be forwarded to the plugin.
+// Plugins call the services of the operator with the same messages, when the operator
+// accepted the "operator-services" feature; their request IDs start with "p".
+// For server-streaming methods, the payload is the single request message and
+// the plugin answers with PluginStreamHeader, PluginStreamFrame and PluginStreamEnd.
+// For client-streaming and bidirectional methods, the payload is empty: request messages
+// follow as PluginStreamFrame, terminated by PluginStreamHalfClose.
+type PluginRPCCall struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RequestId      string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`                // Unique ID to correlate with response
+	Method         string                 `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`                                       // Method name (e.g., "RenewToken", "GetTokenValidity")
+	Payload        []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`                                     // Encoded request message (protocol-specific)
+	Metadata       []*MetadataEntry       `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty"`                                   // Outgoing gRPC metadata of the caller
+	Timeout        *durationpb.Duration   `protobuf:"bytes,5,opt,name=timeout,proto3" json:"timeout,omitempty"`                                     // Time left before the caller's deadline, unset if none
+	IdempotencyKey string                 `protobuf:"bytes,6,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"` // Same for every attempt of a retried call, empty if none
+	PayloadChunks  uint32                 `protobuf:"varint,7,opt,name=payload_chunks,json=payloadChunks,proto3" json:"payload_chunks,omitempty"`   // Number of PluginPayloadChunk carrying the payload, 0 if inline
+	Compression    string                 `protobuf:"bytes,8,opt,name=compression,proto3" json:"compression,omitempty"`                             // Algorithm compressing the payload, empty if uncompressed
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *PluginRPCCall) Reset() {
+	*x = PluginRPCCall{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginRPCCall) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginRPCCall) ProtoMessage() {}
+
+func (x *PluginRPCCall) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginRPCCall.ProtoReflect.Descriptor instead.
+func (*PluginRPCCall) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PluginRPCCall) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginRPCCall) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *PluginRPCCall) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PluginRPCCall) GetMetadata() []*MetadataEntry {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *PluginRPCCall) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+func (x *PluginRPCCall) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *PluginRPCCall) GetPayloadChunks() uint32 {
+	if x != nil {
+		return x.PayloadChunks
+	}
+	return 0
+}
+
+func (x *PluginRPCCall) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
+// PluginRPCResponse is the response from the plugin to an RPC call.
+// Its payload is compressed with the algorithm of the call, if any.
+type PluginRPCResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`              // Correlates with the original RPC call
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`                                   // Encoded response message (protocol-specific)
+	Header        []*MetadataEntry       `protobuf:"bytes,3,rep,name=header,proto3" json:"header,omitempty"`                                     // Header metadata set by the plugin handler
+	Trailer       []*MetadataEntry       `protobuf:"bytes,4,rep,name=trailer,proto3" json:"trailer,omitempty"`                                   // Trailer metadata set by the plugin handler
+	PayloadChunks uint32                 `protobuf:"varint,5,opt,name=payload_chunks,json=payloadChunks,proto3" json:"payload_chunks,omitempty"` // Number of PluginPayloadChunk carrying the payload, 0 if inline
+	Compression   string                 `protobuf:"bytes,6,opt,name=compression,proto3" json:"compression,omitempty"`                           // Algorithm compressing the payload, empty if uncompressed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginRPCResponse) Reset() {
+	*x = PluginRPCResponse{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginRPCResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginRPCResponse) ProtoMessage() {}
+
+func (x *PluginRPCResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginRPCResponse.ProtoReflect.Descriptor instead.
+func (*PluginRPCResponse) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PluginRPCResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginRPCResponse) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PluginRPCResponse) GetHeader() []*MetadataEntry {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *PluginRPCResponse) GetTrailer() []*MetadataEntry {
+	if x != nil {
+		return x.Trailer
+	}
+	return nil
+}
+
+func (x *PluginRPCResponse) GetPayloadChunks() uint32 {
+	if x != nil {
+		return x.PayloadChunks
+	}
+	return 0
+}
+
+func (x *PluginRPCResponse) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
+// PluginCancel is sent by the caller when it gives up on a pending RPC call,
+// so that the handler context on the other side is cancelled too.
+type PluginCancel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Request ID of the call to cancel
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginCancel) Reset() {
+	*x = PluginCancel{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginCancel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginCancel) ProtoMessage() {}
+
+func (x *PluginCancel) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginCancel.ProtoReflect.Descriptor instead.
+func (*PluginCancel) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{9}
 }
 
-type PluginStreamMessage_RpcCall struct {
-	RpcCall *PluginRPCCall `protobuf:"bytes,2,opt,name=rpc_call,json=rpcCall,proto3,oneof"`
+func (x *PluginCancel) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
 }
 
-type PluginStreamMessage_RpcResponse struct {
-	RpcResponse *PluginRPCResponse `protobuf:"bytes,3,opt,name=rpc_response,json=rpcResponse,proto3,oneof"`
+// PluginStreamHeader carries the header metadata of a streaming RPC call.
+// The plugin sends it before the first stream message.
+type PluginStreamHeader struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call
+	Header        []*MetadataEntry       `protobuf:"bytes,2,rep,name=header,proto3" json:"header,omitempty"`                        // Header metadata set by the plugin handler
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-type PluginStreamMessage_Error struct {
-	Error *PluginError `protobuf:"bytes,4,opt,name=error,proto3,oneof"`
+func (x *PluginStreamHeader) Reset() {
+	*x = PluginStreamHeader{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (*PluginStreamMessage_Register) isPluginStreamMessage_Payload() {}
+func (x *PluginStreamHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
 
-func (*PluginStreamMessage_RpcCall) isPluginStreamMessage_Payload() {}
+func (*PluginStreamHeader) ProtoMessage() {}
 
-func (*PluginStreamMessage_RpcResponse) isPluginStreamMessage_Payload() {}
+func (x *PluginStreamHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
 
-func (*PluginStreamMessage_Error) isPluginStreamMessage_Payload() {}
+// Deprecated: Use PluginStreamHeader.ProtoReflect.Descriptor instead.
+func (*PluginStreamHeader) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{10}
+}
 
-// PluginRegister is sent by the plugin when it connects to register itself.
-type PluginRegister struct {
+func (x *PluginStreamHeader) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginStreamHeader) GetHeader() []*MetadataEntry {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+// PluginStreamFrame carries one message of a streaming RPC call, in either direction.
+// A sender may have at most 64 frames per call not yet granted back by
+// PluginStreamWindowUpdate from the receiver.
+type PluginStreamFrame struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`              // Correlates with the original RPC call
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`                                   // Encoded stream message (protocol-specific)
+	PayloadChunks uint32                 `protobuf:"varint,3,opt,name=payload_chunks,json=payloadChunks,proto3" json:"payload_chunks,omitempty"` // Number of PluginPayloadChunk carrying the payload, 0 if inline
+	Compression   string                 `protobuf:"bytes,4,opt,name=compression,proto3" json:"compression,omitempty"`                           // Algorithm compressing the payload, empty if uncompressed
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PluginRegister) Reset() {
-	*x = PluginRegister{}
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[1]
+func (x *PluginStreamFrame) Reset() {
+	*x = PluginStreamFrame{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PluginRegister) String() string {
+func (x *PluginStreamFrame) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PluginRegister) ProtoMessage() {}
+func (*PluginStreamFrame) ProtoMessage() {}
 
-func (x *PluginRegister) ProtoReflect() protoreflect.Message {
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[1]
+func (x *PluginStreamFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -171,50 +1100,65 @@ func (x *PluginRegister) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PluginRegister.ProtoReflect.Descriptor instead.
-func (*PluginRegister) Descriptor() ([]byte, []int) {
-	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use PluginStreamFrame.ProtoReflect.Descriptor instead.
+func (*PluginStreamFrame) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *PluginRegister) GetName() string {
+func (x *PluginStreamFrame) GetRequestId() string {
 	if x != nil {
-		return x.Name
+		return x.RequestId
 	}
 	return ""
 }
 
-func (x *PluginRegister) GetVersion() string {
+func (x *PluginStreamFrame) GetPayload() []byte {
 	if x != nil {
-		return x.Version
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PluginStreamFrame) GetPayloadChunks() uint32 {
+	if x != nil {
+		return x.PayloadChunks
+	}
+	return 0
+}
+
+func (x *PluginStreamFrame) GetCompression() string {
+	if x != nil {
+		return x.Compression
 	}
 	return ""
 }
 
-// PluginRPCCall represents an RPC method call to be forwarded to the plugin.
-type PluginRPCCall struct {
+// PluginStreamEnd is the end-of-stream frame of a streaming RPC call.
+// It carries the final status returned by the plugin handler.
+type PluginStreamEnd struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Unique ID to correlate with response
-	Method        string                 `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`                        // Method name (e.g., "RenewToken", "GetTokenValidity")
-	Payload       []byte                 `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`                      // Encoded request message (protocol-specific)
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call
+	Status        *status.Status         `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`                        // Final status, OK when the handler succeeded
+	Trailer       []*MetadataEntry       `protobuf:"bytes,3,rep,name=trailer,proto3" json:"trailer,omitempty"`                      // Trailer metadata set by the plugin handler
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PluginRPCCall) Reset() {
-	*x = PluginRPCCall{}
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[2]
+func (x *PluginStreamEnd) Reset() {
+	*x = PluginStreamEnd{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PluginRPCCall) String() string {
+func (x *PluginStreamEnd) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PluginRPCCall) ProtoMessage() {}
+func (*PluginStreamEnd) ProtoMessage() {}
 
-func (x *PluginRPCCall) ProtoReflect() protoreflect.Message {
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[2]
+func (x *PluginStreamEnd) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -225,56 +1169,56 @@ func (x *PluginRPCCall) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PluginRPCCall.ProtoReflect.Descriptor instead.
-func (*PluginRPCCall) Descriptor() ([]byte, []int) {
-	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use PluginStreamEnd.ProtoReflect.Descriptor instead.
+func (*PluginStreamEnd) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *PluginRPCCall) GetRequestId() string {
+func (x *PluginStreamEnd) GetRequestId() string {
 	if x != nil {
 		return x.RequestId
 	}
 	return ""
 }
 
-func (x *PluginRPCCall) GetMethod() string {
+func (x *PluginStreamEnd) GetStatus() *status.Status {
 	if x != nil {
-		return x.Method
+		return x.Status
 	}
-	return ""
+	return nil
 }
 
-func (x *PluginRPCCall) GetPayload() []byte {
+func (x *PluginStreamEnd) GetTrailer() []*MetadataEntry {
 	if x != nil {
-		return x.Payload
+		return x.Trailer
 	}
 	return nil
 }
 
-// PluginRPCResponse is the response from the plugin to an RPC call.
-type PluginRPCResponse struct {
+// PluginStreamHalfClose is sent by the caller when it has no more messages to send
+// on a client-streaming or bidirectional RPC call.
+type PluginStreamHalfClose struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call
-	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`                      // Encoded response message (protocol-specific)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PluginRPCResponse) Reset() {
-	*x = PluginRPCResponse{}
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[3]
+func (x *PluginStreamHalfClose) Reset() {
+	*x = PluginStreamHalfClose{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PluginRPCResponse) String() string {
+func (x *PluginStreamHalfClose) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PluginRPCResponse) ProtoMessage() {}
+func (*PluginStreamHalfClose) ProtoMessage() {}
 
-func (x *PluginRPCResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[3]
+func (x *PluginStreamHalfClose) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -285,37 +1229,207 @@ func (x *PluginRPCResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PluginRPCResponse.ProtoReflect.Descriptor instead.
-func (*PluginRPCResponse) Descriptor() ([]byte, []int) {
-	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use PluginStreamHalfClose.ProtoReflect.Descriptor instead.
+func (*PluginStreamHalfClose) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *PluginRPCResponse) GetRequestId() string {
+func (x *PluginStreamHalfClose) GetRequestId() string {
 	if x != nil {
 		return x.RequestId
 	}
 	return ""
 }
 
-func (x *PluginRPCResponse) GetPayload() []byte {
+// PluginStreamWindowUpdate grants the other side permission to send more frames
+// on a streaming RPC call (per-call flow control).
+type PluginStreamWindowUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call
+	Messages      uint32                 `protobuf:"varint,2,opt,name=messages,proto3" json:"messages,omitempty"`                   // Number of additional frames the sender may send
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginStreamWindowUpdate) Reset() {
+	*x = PluginStreamWindowUpdate{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginStreamWindowUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginStreamWindowUpdate) ProtoMessage() {}
+
+func (x *PluginStreamWindowUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[14]
 	if x != nil {
-		return x.Payload
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginStreamWindowUpdate.ProtoReflect.Descriptor instead.
+func (*PluginStreamWindowUpdate) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PluginStreamWindowUpdate) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginStreamWindowUpdate) GetMessages() uint32 {
+	if x != nil {
+		return x.Messages
+	}
+	return 0
+}
+
+// PluginPayloadChunk carries part of a payload too large for a single message.
+// The chunks of a payload are sent in order, right before the message they belong to
+// (PluginRPCCall, PluginRPCResponse or PluginStreamFrame), which has an empty payload
+// and the number of chunks in payload_chunks. Other calls may interleave their messages.
+type PluginPayloadChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call
+	Index         uint32                 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`                         // Position of the chunk in the payload, starting at 0
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                            // Part of the payload
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginPayloadChunk) Reset() {
+	*x = PluginPayloadChunk{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginPayloadChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginPayloadChunk) ProtoMessage() {}
+
+func (x *PluginPayloadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginPayloadChunk.ProtoReflect.Descriptor instead.
+func (*PluginPayloadChunk) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PluginPayloadChunk) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginPayloadChunk) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *PluginPayloadChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// MetadataEntry is a single gRPC metadata key with its values.
+// Values are bytes so that binary ("-bin") keys survive the round trip.
+type MetadataEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Values        [][]byte               `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetadataEntry) Reset() {
+	*x = MetadataEntry{}
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetadataEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetadataEntry) ProtoMessage() {}
+
+func (x *MetadataEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetadataEntry.ProtoReflect.Descriptor instead.
+func (*MetadataEntry) Descriptor() ([]byte, []int) {
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *MetadataEntry) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *MetadataEntry) GetValues() [][]byte {
+	if x != nil {
+		return x.Values
 	}
 	return nil
 }
 
 // PluginError represents an error in plugin communication.
+// When request_id is set, the error completes that RPC call with the given status.
 type PluginError struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	RequestId     string                 `protobuf:"bytes,3,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Correlates with the original RPC call, empty for stream-level errors
+	Status        *status.Status         `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`                        // Full status returned by the plugin handler, including details
+	Header        []*MetadataEntry       `protobuf:"bytes,5,rep,name=header,proto3" json:"header,omitempty"`                        // Header metadata set by the plugin handler
+	Trailer       []*MetadataEntry       `protobuf:"bytes,6,rep,name=trailer,proto3" json:"trailer,omitempty"`                      // Trailer metadata set by the plugin handler
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *PluginError) Reset() {
 	*x = PluginError{}
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[4]
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -327,7 +1441,7 @@ func (x *PluginError) String() string {
 func (*PluginError) ProtoMessage() {}
 
 func (x *PluginError) ProtoReflect() protoreflect.Message {
-	mi := &file_pluginframework_v1_stream_proto_msgTypes[4]
+	mi := &file_pluginframework_v1_stream_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,7 +1454,7 @@ func (x *PluginError) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PluginError.ProtoReflect.Descriptor instead.
 func (*PluginError) Descriptor() ([]byte, []int) {
-	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{4}
+	return file_pluginframework_v1_stream_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *PluginError) GetMessage() string {
@@ -357,32 +1471,154 @@ func (x *PluginError) GetCode() string {
 	return ""
 }
 
+func (x *PluginError) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PluginError) GetStatus() *status.Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *PluginError) GetHeader() []*MetadataEntry {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *PluginError) GetTrailer() []*MetadataEntry {
+	if x != nil {
+		return x.Trailer
+	}
+	return nil
+}
+
 var File_pluginframework_v1_stream_proto protoreflect.FileDescriptor
 
 const file_pluginframework_v1_stream_proto_rawDesc = "" +
 	"\n" +
-	"\x1fpluginframework/v1/stream.proto\x12\x12pluginframework.v1\"\xa7\x02\n" +
+	"\x1fpluginframework/v1/stream.proto\x12\x12pluginframework.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x17google/rpc/status.proto\"\xa2\t\n" +
 	"\x13PluginStreamMessage\x12@\n" +
 	"\bregister\x18\x01 \x01(\v2\".pluginframework.v1.PluginRegisterH\x00R\bregister\x12>\n" +
 	"\brpc_call\x18\x02 \x01(\v2!.pluginframework.v1.PluginRPCCallH\x00R\arpcCall\x12J\n" +
 	"\frpc_response\x18\x03 \x01(\v2%.pluginframework.v1.PluginRPCResponseH\x00R\vrpcResponse\x127\n" +
-	"\x05error\x18\x04 \x01(\v2\x1f.pluginframework.v1.PluginErrorH\x00R\x05errorB\t\n" +
-	"\apayload\">\n" +
+	"\x05error\x18\x04 \x01(\v2\x1f.pluginframework.v1.PluginErrorH\x00R\x05error\x12:\n" +
+	"\x06cancel\x18\x05 \x01(\v2 .pluginframework.v1.PluginCancelH\x00R\x06cancel\x12M\n" +
+	"\rstream_header\x18\x06 \x01(\v2&.pluginframework.v1.PluginStreamHeaderH\x00R\fstreamHeader\x12J\n" +
+	"\fstream_frame\x18\a \x01(\v2%.pluginframework.v1.PluginStreamFrameH\x00R\vstreamFrame\x12D\n" +
+	"\n" +
+	"stream_end\x18\b \x01(\v2#.pluginframework.v1.PluginStreamEndH\x00R\tstreamEnd\x12W\n" +
+	"\x11stream_half_close\x18\t \x01(\v2).pluginframework.v1.PluginStreamHalfCloseH\x00R\x0fstreamHalfClose\x12`\n" +
+	"\x14stream_window_update\x18\n" +
+	" \x01(\v2,.pluginframework.v1.PluginStreamWindowUpdateH\x00R\x12streamWindowUpdate\x12M\n" +
+	"\rpayload_chunk\x18\v \x01(\v2&.pluginframework.v1.PluginPayloadChunkH\x00R\fpayloadChunk\x12J\n" +
+	"\fregister_ack\x18\f \x01(\v2%.pluginframework.v1.PluginRegisterAckH\x00R\vregisterAck\x12S\n" +
+	"\x0fservices_update\x18\r \x01(\v2(.pluginframework.v1.PluginServicesUpdateH\x00R\x0eservicesUpdate\x12G\n" +
+	"\vsession_ack\x18\x0f \x01(\v2$.pluginframework.v1.PluginSessionAckH\x00R\n" +
+	"sessionAck\x12L\n" +
+	"\fnotification\x18\x10 \x01(\v2&.pluginframework.v1.PluginNotificationH\x00R\fnotification\x12\x1a\n" +
+	"\bsequence\x18\x0e \x01(\x04R\bsequenceB\t\n" +
+	"\apayload\"\xb8\x02\n" +
 	"\x0ePluginRegister\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
-	"\aversion\x18\x02 \x01(\tR\aversion\"`\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12 \n" +
+	"\vcompression\x18\x03 \x03(\tR\vcompression\x12)\n" +
+	"\x10protocol_version\x18\x04 \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bfeatures\x18\x05 \x03(\tR\bfeatures\x12\x18\n" +
+	"\amethods\x18\x06 \x03(\tR\amethods\x12)\n" +
+	"\x10file_descriptors\x18\a \x03(\fR\x0ffileDescriptors\x12!\n" +
+	"\fresume_token\x18\b \x01(\tR\vresumeToken\x12'\n" +
+	"\x0fresume_sequence\x18\t \x01(\x04R\x0eresumeSequence\"\xb6\x03\n" +
+	"\x11PluginRegisterAck\x120\n" +
+	"\trejection\x18\x01 \x01(\v2\x12.google.rpc.StatusR\trejection\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bfeatures\x18\x04 \x03(\tR\bfeatures\x12>\n" +
+	"\x06limits\x18\x05 \x01(\v2&.pluginframework.v1.PluginServerLimitsR\x06limits\x12!\n" +
+	"\fresume_token\x18\x06 \x01(\tR\vresumeToken\x12I\n" +
+	"\x13resume_grace_period\x18\a \x01(\v2\x19.google.protobuf.DurationR\x11resumeGracePeriod\x12\x18\n" +
+	"\aresumed\x18\b \x01(\bR\aresumed\x12'\n" +
+	"\x0fresume_sequence\x18\t \x01(\x04R\x0eresumeSequence\x12\x18\n" +
+	"\amethods\x18\n" +
+	" \x03(\tR\amethods\"h\n" +
+	"\x12PluginServerLimits\x12(\n" +
+	"\x10max_message_size\x18\x01 \x01(\rR\x0emaxMessageSize\x12(\n" +
+	"\x10max_payload_size\x18\x02 \x01(\rR\x0emaxPayloadSize\"[\n" +
+	"\x14PluginServicesUpdate\x12\x18\n" +
+	"\amethods\x18\x01 \x03(\tR\amethods\x12)\n" +
+	"\x10file_descriptors\x18\x02 \x03(\fR\x0ffileDescriptors\"^\n" +
+	"\x12PluginNotification\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\".\n" +
+	"\x10PluginSessionAck\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\"\xc6\x02\n" +
 	"\rPluginRPCCall\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12\x16\n" +
 	"\x06method\x18\x02 \x01(\tR\x06method\x12\x18\n" +
-	"\apayload\x18\x03 \x01(\fR\apayload\"L\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\x12=\n" +
+	"\bmetadata\x18\x04 \x03(\v2!.pluginframework.v1.MetadataEntryR\bmetadata\x123\n" +
+	"\atimeout\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\atimeout\x12'\n" +
+	"\x0fidempotency_key\x18\x06 \x01(\tR\x0eidempotencyKey\x12%\n" +
+	"\x0epayload_chunks\x18\a \x01(\rR\rpayloadChunks\x12 \n" +
+	"\vcompression\x18\b \x01(\tR\vcompression\"\x8d\x02\n" +
 	"\x11PluginRPCResponse\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12\x18\n" +
-	"\apayload\x18\x02 \x01(\fR\apayload\";\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\x129\n" +
+	"\x06header\x18\x03 \x03(\v2!.pluginframework.v1.MetadataEntryR\x06header\x12;\n" +
+	"\atrailer\x18\x04 \x03(\v2!.pluginframework.v1.MetadataEntryR\atrailer\x12%\n" +
+	"\x0epayload_chunks\x18\x05 \x01(\rR\rpayloadChunks\x12 \n" +
+	"\vcompression\x18\x06 \x01(\tR\vcompression\"-\n" +
+	"\fPluginCancel\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\"n\n" +
+	"\x12PluginStreamHeader\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x129\n" +
+	"\x06header\x18\x02 \x03(\v2!.pluginframework.v1.MetadataEntryR\x06header\"\x95\x01\n" +
+	"\x11PluginStreamFrame\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\fR\apayload\x12%\n" +
+	"\x0epayload_chunks\x18\x03 \x01(\rR\rpayloadChunks\x12 \n" +
+	"\vcompression\x18\x04 \x01(\tR\vcompression\"\x99\x01\n" +
+	"\x0fPluginStreamEnd\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12*\n" +
+	"\x06status\x18\x02 \x01(\v2\x12.google.rpc.StatusR\x06status\x12;\n" +
+	"\atrailer\x18\x03 \x03(\v2!.pluginframework.v1.MetadataEntryR\atrailer\"6\n" +
+	"\x15PluginStreamHalfClose\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\"U\n" +
+	"\x18PluginStreamWindowUpdate\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x1a\n" +
+	"\bmessages\x18\x02 \x01(\rR\bmessages\"]\n" +
+	"\x12PluginPayloadChunk\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\rR\x05index\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"9\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x16\n" +
+	"\x06values\x18\x02 \x03(\fR\x06values\"\xfe\x01\n" +
 	"\vPluginError\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\x12\x12\n" +
-	"\x04code\x18\x02 \x01(\tR\x04code2~\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x03 \x01(\tR\trequestId\x12*\n" +
+	"\x06status\x18\x04 \x01(\v2\x12.google.rpc.StatusR\x06status\x129\n" +
+	"\x06header\x18\x05 \x03(\v2!.pluginframework.v1.MetadataEntryR\x06header\x12;\n" +
+	"\atrailer\x18\x06 \x03(\v2!.pluginframework.v1.MetadataEntryR\atrailer2~\n" +
 	"\x16PluginFrameworkService\x12d\n" +
 	"\fPluginStream\x12'.pluginframework.v1.PluginStreamMessage\x1a'.pluginframework.v1.PluginStreamMessage(\x010\x01B\xe1\x01\n" +
 	"\x16com.pluginframework.v1B\vStreamProtoP\x01ZQgithub.com/guilhem/operator-plugin-framework/pluginframework/v1;pluginframeworkv1\xa2\x02\x03PXX\xaa\x02\x12Pluginframework.V1\xca\x02\x12Pluginframework\\V1\xe2\x02\x1ePluginframework\\V1\\GPBMetadata\xea\x02\x13Pluginframework::V1b\x06proto3"
@@ -399,26 +1635,65 @@ func file_pluginframework_v1_stream_proto_rawDescGZIP() []byte {
 	return file_pluginframework_v1_stream_proto_rawDescData
 }
 
-var file_pluginframework_v1_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_pluginframework_v1_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_pluginframework_v1_stream_proto_goTypes = []any{
-	(*PluginStreamMessage)(nil), // 0: pluginframework.v1.PluginStreamMessage
-	(*PluginRegister)(nil),      // 1: pluginframework.v1.PluginRegister
-	(*PluginRPCCall)(nil),       // 2: pluginframework.v1.PluginRPCCall
-	(*PluginRPCResponse)(nil),   // 3: pluginframework.v1.PluginRPCResponse
-	(*PluginError)(nil),         // 4: pluginframework.v1.PluginError
+	(*PluginStreamMessage)(nil),      // 0: pluginframework.v1.PluginStreamMessage
+	(*PluginRegister)(nil),           // 1: pluginframework.v1.PluginRegister
+	(*PluginRegisterAck)(nil),        // 2: pluginframework.v1.PluginRegisterAck
+	(*PluginServerLimits)(nil),       // 3: pluginframework.v1.PluginServerLimits
+	(*PluginServicesUpdate)(nil),     // 4: pluginframework.v1.PluginServicesUpdate
+	(*PluginNotification)(nil),       // 5: pluginframework.v1.PluginNotification
+	(*PluginSessionAck)(nil),         // 6: pluginframework.v1.PluginSessionAck
+	(*PluginRPCCall)(nil),            // 7: pluginframework.v1.PluginRPCCall
+	(*PluginRPCResponse)(nil),        // 8: pluginframework.v1.PluginRPCResponse
+	(*PluginCancel)(nil),             // 9: pluginframework.v1.PluginCancel
+	(*PluginStreamHeader)(nil),       // 10: pluginframework.v1.PluginStreamHeader
+	(*PluginStreamFrame)(nil),        // 11: pluginframework.v1.PluginStreamFrame
+	(*PluginStreamEnd)(nil),          // 12: pluginframework.v1.PluginStreamEnd
+	(*PluginStreamHalfClose)(nil),    // 13: pluginframework.v1.PluginStreamHalfClose
+	(*PluginStreamWindowUpdate)(nil), // 14: pluginframework.v1.PluginStreamWindowUpdate
+	(*PluginPayloadChunk)(nil),       // 15: pluginframework.v1.PluginPayloadChunk
+	(*MetadataEntry)(nil),            // 16: pluginframework.v1.MetadataEntry
+	(*PluginError)(nil),              // 17: pluginframework.v1.PluginError
+	(*status.Status)(nil),            // 18: google.rpc.Status
+	(*durationpb.Duration)(nil),      // 19: google.protobuf.Duration
 }
 var file_pluginframework_v1_stream_proto_depIdxs = []int32{
-	1, // 0: pluginframework.v1.PluginStreamMessage.register:type_name -> pluginframework.v1.PluginRegister
-	2, // 1: pluginframework.v1.PluginStreamMessage.rpc_call:type_name -> pluginframework.v1.PluginRPCCall
-	3, // 2: pluginframework.v1.PluginStreamMessage.rpc_response:type_name -> pluginframework.v1.PluginRPCResponse
-	4, // 3: pluginframework.v1.PluginStreamMessage.error:type_name -> pluginframework.v1.PluginError
-	0, // 4: pluginframework.v1.PluginFrameworkService.PluginStream:input_type -> pluginframework.v1.PluginStreamMessage
-	0, // 5: pluginframework.v1.PluginFrameworkService.PluginStream:output_type -> pluginframework.v1.PluginStreamMessage
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	1,  // 0: pluginframework.v1.PluginStreamMessage.register:type_name -> pluginframework.v1.PluginRegister
+	7,  // 1: pluginframework.v1.PluginStreamMessage.rpc_call:type_name -> pluginframework.v1.PluginRPCCall
+	8,  // 2: pluginframework.v1.PluginStreamMessage.rpc_response:type_name -> pluginframework.v1.PluginRPCResponse
+	17, // 3: pluginframework.v1.PluginStreamMessage.error:type_name -> pluginframework.v1.PluginError
+	9,  // 4: pluginframework.v1.PluginStreamMessage.cancel:type_name -> pluginframework.v1.PluginCancel
+	10, // 5: pluginframework.v1.PluginStreamMessage.stream_header:type_name -> pluginframework.v1.PluginStreamHeader
+	11, // 6: pluginframework.v1.PluginStreamMessage.stream_frame:type_name -> pluginframework.v1.PluginStreamFrame
+	12, // 7: pluginframework.v1.PluginStreamMessage.stream_end:type_name -> pluginframework.v1.PluginStreamEnd
+	13, // 8: pluginframework.v1.PluginStreamMessage.stream_half_close:type_name -> pluginframework.v1.PluginStreamHalfClose
+	14, // 9: pluginframework.v1.PluginStreamMessage.stream_window_update:type_name -> pluginframework.v1.PluginStreamWindowUpdate
+	15, // 10: pluginframework.v1.PluginStreamMessage.payload_chunk:type_name -> pluginframework.v1.PluginPayloadChunk
+	2,  // 11: pluginframework.v1.PluginStreamMessage.register_ack:type_name -> pluginframework.v1.PluginRegisterAck
+	4,  // 12: pluginframework.v1.PluginStreamMessage.services_update:type_name -> pluginframework.v1.PluginServicesUpdate
+	6,  // 13: pluginframework.v1.PluginStreamMessage.session_ack:type_name -> pluginframework.v1.PluginSessionAck
+	5,  // 14: pluginframework.v1.PluginStreamMessage.notification:type_name -> pluginframework.v1.PluginNotification
+	18, // 15: pluginframework.v1.PluginRegisterAck.rejection:type_name -> google.rpc.Status
+	3,  // 16: pluginframework.v1.PluginRegisterAck.limits:type_name -> pluginframework.v1.PluginServerLimits
+	19, // 17: pluginframework.v1.PluginRegisterAck.resume_grace_period:type_name -> google.protobuf.Duration
+	16, // 18: pluginframework.v1.PluginRPCCall.metadata:type_name -> pluginframework.v1.MetadataEntry
+	19, // 19: pluginframework.v1.PluginRPCCall.timeout:type_name -> google.protobuf.Duration
+	16, // 20: pluginframework.v1.PluginRPCResponse.header:type_name -> pluginframework.v1.MetadataEntry
+	16, // 21: pluginframework.v1.PluginRPCResponse.trailer:type_name -> pluginframework.v1.MetadataEntry
+	16, // 22: pluginframework.v1.PluginStreamHeader.header:type_name -> pluginframework.v1.MetadataEntry
+	18, // 23: pluginframework.v1.PluginStreamEnd.status:type_name -> google.rpc.Status
+	16, // 24: pluginframework.v1.PluginStreamEnd.trailer:type_name -> pluginframework.v1.MetadataEntry
+	18, // 25: pluginframework.v1.PluginError.status:type_name -> google.rpc.Status
+	16, // 26: pluginframework.v1.PluginError.header:type_name -> pluginframework.v1.MetadataEntry
+	16, // 27: pluginframework.v1.PluginError.trailer:type_name -> pluginframework.v1.MetadataEntry
+	0,  // 28: pluginframework.v1.PluginFrameworkService.PluginStream:input_type -> pluginframework.v1.PluginStreamMessage
+	0,  // 29: pluginframework.v1.PluginFrameworkService.PluginStream:output_type -> pluginframework.v1.PluginStreamMessage
+	29, // [29:30] is the sub-list for method output_type
+	28, // [28:29] is the sub-list for method input_type
+	28, // [28:28] is the sub-list for extension type_name
+	28, // [28:28] is the sub-list for extension extendee
+	0,  // [0:28] is the sub-list for field type_name
 }
 
 func init() { file_pluginframework_v1_stream_proto_init() }
@@ -431,6 +1706,17 @@ func file_pluginframework_v1_stream_proto_init() {
 		(*PluginStreamMessage_RpcCall)(nil),
 		(*PluginStreamMessage_RpcResponse)(nil),
 		(*PluginStreamMessage_Error)(nil),
+		(*PluginStreamMessage_Cancel)(nil),
+		(*PluginStreamMessage_StreamHeader)(nil),
+		(*PluginStreamMessage_StreamFrame)(nil),
+		(*PluginStreamMessage_StreamEnd)(nil),
+		(*PluginStreamMessage_StreamHalfClose)(nil),
+		(*PluginStreamMessage_StreamWindowUpdate)(nil),
+		(*PluginStreamMessage_PayloadChunk)(nil),
+		(*PluginStreamMessage_RegisterAck)(nil),
+		(*PluginStreamMessage_ServicesUpdate)(nil),
+		(*PluginStreamMessage_SessionAck)(nil),
+		(*PluginStreamMessage_Notification)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -438,7 +1724,7 @@ func file_pluginframework_v1_stream_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pluginframework_v1_stream_proto_rawDesc), len(file_pluginframework_v1_stream_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,248 @@
+// Package registration provides an optional controller reflecting the plugins connected
+// to a server.Server into PluginRegistration objects, so that cluster admins can see them:
+//
+//	kubectl get pluginregistrations -n my-operator
+//
+// Register the types of the api/v1alpha1 package in the scheme of the manager, install
+// the CRD of config/crd, and add the controller to the manager:
+//
+//	if err := registration.New(mgr.GetClient(), pluginServer, namespace).SetupWithManager(mgr); err != nil {
+//	    return err
+//	}
+package registration
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pluginframeworkv1alpha1 "github.com/guilhem/operator-plugin-framework/api/v1alpha1"
+	"github.com/guilhem/operator-plugin-framework/server"
+)
+
+const (
+	// DefaultResyncPeriod is how often the status of a connected plugin is refreshed.
+	DefaultResyncPeriod = 30 * time.Second
+
+	// DefaultDisconnectedTTL is how long the object of a disconnected plugin is kept.
+	DefaultDisconnectedTTL = 10 * time.Minute
+
+	// DefaultHealthCheckTimeout bounds the health checks of the plugins.
+	DefaultHealthCheckTimeout = 5 * time.Second
+)
+
+// healthCheckMethod is the method called to check the health of the plugins serving it.
+const healthCheckMethod = "/grpc.health.v1.Health/Check"
+
+// Reconciler keeps a PluginRegistration object per plugin connected to a server, named
+// after the plugin, in the namespace of the operator.
+// The objects of plugins gone for longer than the disconnected TTL are deleted.
+type Reconciler struct {
+	client    client.Client
+	server    *server.Server
+	namespace string
+
+	resyncPeriod       time.Duration
+	disconnectedTTL    time.Duration
+	healthCheckTimeout time.Duration
+}
+
+// Option is a functional option for Reconciler configuration.
+type Option func(*Reconciler)
+
+// WithResyncPeriod sets how often the status of a connected plugin is refreshed.
+func WithResyncPeriod(period time.Duration) Option {
+	return func(r *Reconciler) {
+		if period > 0 {
+			r.resyncPeriod = period
+		}
+	}
+}
+
+// WithDisconnectedTTL sets how long the object of a disconnected plugin is kept,
+// for the plugin to come back, before it is deleted.
+func WithDisconnectedTTL(ttl time.Duration) Option {
+	return func(r *Reconciler) {
+		if ttl >= 0 {
+			r.disconnectedTTL = ttl
+		}
+	}
+}
+
+// WithHealthCheckTimeout sets the timeout of the health checks of the plugins.
+func WithHealthCheckTimeout(timeout time.Duration) Option {
+	return func(r *Reconciler) {
+		if timeout > 0 {
+			r.healthCheckTimeout = timeout
+		}
+	}
+}
+
+// New creates a Reconciler keeping the PluginRegistration objects of the plugins of srv
+// in namespace.
+func New(c client.Client, srv *server.Server, namespace string, opts ...Option) *Reconciler {
+	r := &Reconciler{
+		client:             c,
+		server:             srv,
+		namespace:          namespace,
+		resyncPeriod:       DefaultResyncPeriod,
+		disconnectedTTL:    DefaultDisconnectedTTL,
+		healthCheckTimeout: DefaultHealthCheckTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// SetupWithManager adds the controller to mgr. It reconciles the objects of the namespace
+// when their spec changes, and the plugins when they connect, disconnect or change version.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	pluginRequest := func(_ context.Context, evt server.PluginEvent) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: r.namespace, Name: evt.Plugin}}}
+	}
+	inNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.namespace
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pluginregistration").
+		For(&pluginframeworkv1alpha1.PluginRegistration{}, builder.WithPredicates(inNamespace, predicate.GenerationChangedPredicate{})).
+		WatchesRawSource(r.server.Source(handler.TypedEnqueueRequestsFromMapFunc(pluginRequest))).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=pluginframework.guilhem.github.io,resources=pluginregistrations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=pluginframework.guilhem.github.io,resources=pluginregistrations/status,verbs=get;update;patch
+
+// Reconcile brings the object of a plugin in line with its connection to the server.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if req.Namespace != r.namespace {
+		return ctrl.Result{}, nil
+	}
+
+	reg := &pluginframeworkv1alpha1.PluginRegistration{}
+	err := r.client.Get(ctx, req.NamespacedName, reg)
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get plugin registration: %w", err)
+	}
+	exists := err == nil
+
+	info := r.server.GetStreamManager().GetPluginInfo(req.Name)
+	if info == nil {
+		if !exists {
+			return ctrl.Result{}, nil
+		}
+		return r.reconcileDisconnected(ctx, reg)
+	}
+
+	if !exists {
+		reg = &pluginframeworkv1alpha1.PluginRegistration{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+		}
+		if err := r.client.Create(ctx, reg); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create plugin registration: %w", err)
+		}
+	}
+
+	status := reg.Status.DeepCopy()
+	status.Version = info.Version
+	status.Replicas = int32(info.Replicas)
+	status.Methods = slices.Sorted(slices.Values(info.Methods))
+	status.DisconnectedSince = nil
+	if status.ConnectedSince == nil {
+		status.ConnectedSince = &metav1.Time{Time: info.ConnectedAt}
+	}
+	status.Health, status.Message = r.checkHealth(ctx, req.Name, info.Methods)
+	heartbeat := info.LastMessageAt
+	if status.Health == pluginframeworkv1alpha1.PluginHealthy && slices.Contains(info.Methods, healthCheckMethod) {
+		// The plugin just answered its health check
+		heartbeat = time.Now()
+	}
+	// The heartbeat is only refreshed once per resync, not to write the status on each pass
+	if status.LastHeartbeat == nil || heartbeat.Sub(status.LastHeartbeat.Time) >= r.resyncPeriod {
+		status.LastHeartbeat = &metav1.Time{Time: heartbeat}
+	}
+
+	if err := r.updateStatus(ctx, reg, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.resyncPeriod}, nil
+}
+
+// reconcileDisconnected records that a plugin is gone, and deletes its object once
+// the disconnected TTL elapsed.
+func (r *Reconciler) reconcileDisconnected(ctx context.Context, reg *pluginframeworkv1alpha1.PluginRegistration) (ctrl.Result, error) {
+	if reg.Status.DisconnectedSince == nil {
+		status := reg.Status.DeepCopy()
+		status.Replicas = 0
+		status.Health = pluginframeworkv1alpha1.PluginDisconnected
+		status.Message = ""
+		status.ConnectedSince = nil
+		status.DisconnectedSince = &metav1.Time{Time: time.Now()}
+		if err := r.updateStatus(ctx, reg, status); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if remaining := r.disconnectedTTL - time.Since(reg.Status.DisconnectedSince.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	if err := r.client.Delete(ctx, reg); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to delete plugin registration: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// checkHealth returns the health of a connected plugin. Plugins serving the gRPC health
+// service are healthy when their overall health check reports SERVING.
+func (r *Reconciler) checkHealth(ctx context.Context, pluginName string, methods []string) (pluginframeworkv1alpha1.PluginHealth, string) {
+	if !slices.Contains(methods, healthCheckMethod) {
+		return pluginframeworkv1alpha1.PluginHealthy, ""
+	}
+
+	conn, err := r.server.PluginConn(pluginName)
+	if err != nil {
+		return pluginframeworkv1alpha1.PluginUnhealthy, err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.healthCheckTimeout)
+	defer cancel()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return pluginframeworkv1alpha1.PluginUnhealthy, fmt.Sprintf("health check failed: %v", err)
+	}
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return pluginframeworkv1alpha1.PluginUnhealthy, fmt.Sprintf("health check reported %s", resp.GetStatus())
+	}
+	return pluginframeworkv1alpha1.PluginHealthy, ""
+}
+
+// updateStatus writes status to the object of a plugin if it changed.
+func (r *Reconciler) updateStatus(ctx context.Context, reg *pluginframeworkv1alpha1.PluginRegistration, status *pluginframeworkv1alpha1.PluginRegistrationStatus) error {
+	if equality.Semantic.DeepEqual(&reg.Status, status) {
+		return nil
+	}
+
+	reg.Status = *status
+	if err := r.client.Status().Update(ctx, reg); err != nil {
+		return fmt.Errorf("failed to update plugin registration status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pluginframeworkv1alpha1 "github.com/guilhem/operator-plugin-framework/api/v1alpha1"
+	pluginclient "github.com/guilhem/operator-plugin-framework/client"
+	"github.com/guilhem/operator-plugin-framework/server"
+)
+
+const testNamespace = "operator-system"
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := pluginframeworkv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&pluginframeworkv1alpha1.PluginRegistration{}).
+		WithObjects(objs...).
+		Build()
+}
+
+func startServer(t *testing.T) (*server.Server, string) {
+	t.Helper()
+
+	addr := fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock"))
+	s := server.New(addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-errs
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	return s, addr
+}
+
+func request(name string) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: name}}
+}
+
+func TestReconcileConnectedPlugin(t *testing.T) {
+	s, addr := startServer(t)
+	c := newFakeClient(t)
+	r := New(c, s, testNamespace, WithResyncPeriod(time.Minute), WithDisconnectedTTL(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	healthServer := health.NewServer()
+	plugin, err := pluginclient.New(ctx, "health-plugin", addr, "v1.2.0", grpc_health_v1.Health_ServiceDesc, healthServer)
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = plugin.Close() }()
+	go func() { _ = plugin.HandleRPCCalls(ctx) }()
+
+	result, err := r.Reconcile(ctx, request("health-plugin"))
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != time.Minute {
+		t.Errorf("expected a resync after a minute, got %v", result.RequeueAfter)
+	}
+
+	reg := &pluginframeworkv1alpha1.PluginRegistration{}
+	if err := c.Get(ctx, request("health-plugin").NamespacedName, reg); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reg.Status.Version != "v1.2.0" || reg.Status.Replicas != 1 {
+		t.Errorf("expected version v1.2.0 with 1 replica, got %q with %d", reg.Status.Version, reg.Status.Replicas)
+	}
+	if reg.Status.Health != pluginframeworkv1alpha1.PluginHealthy {
+		t.Errorf("expected a healthy plugin, got %s: %s", reg.Status.Health, reg.Status.Message)
+	}
+	if !slices.Contains(reg.Status.Methods, healthCheckMethod) {
+		t.Errorf("expected the health check method to be advertised, got %v", reg.Status.Methods)
+	}
+	if reg.Status.ConnectedSince == nil || reg.Status.LastHeartbeat == nil {
+		t.Errorf("expected connection and heartbeat times, got %+v", reg.Status)
+	}
+	connectedSince := reg.Status.ConnectedSince
+
+	// Nothing changed, so the object is not updated again
+	resourceVersion := reg.ResourceVersion
+	if _, err := r.Reconcile(ctx, request("health-plugin")); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := c.Get(ctx, request("health-plugin").NamespacedName, reg); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reg.ResourceVersion != resourceVersion {
+		t.Errorf("expected no update without changes, resource version went from %s to %s", resourceVersion, reg.ResourceVersion)
+	}
+
+	// A failing health check makes the plugin unhealthy
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if _, err := r.Reconcile(ctx, request("health-plugin")); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := c.Get(ctx, request("health-plugin").NamespacedName, reg); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reg.Status.Health != pluginframeworkv1alpha1.PluginUnhealthy || reg.Status.Message == "" {
+		t.Errorf("expected an unhealthy plugin with a message, got %s: %q", reg.Status.Health, reg.Status.Message)
+	}
+	if !reg.Status.ConnectedSince.Equal(connectedSince) {
+		t.Errorf("expected connection time to be kept, got %v instead of %v", reg.Status.ConnectedSince, connectedSince)
+	}
+
+	// A disconnected plugin keeps its object until the TTL elapses
+	_ = plugin.Close()
+	for s.IsPluginConnected("health-plugin") {
+		select {
+		case <-ctx.Done():
+			t.Fatal("plugin still connected")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	result, err = r.Reconcile(ctx, request("health-plugin"))
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Errorf("expected a requeue at the end of the TTL, got %v", result.RequeueAfter)
+	}
+	if err := c.Get(ctx, request("health-plugin").NamespacedName, reg); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reg.Status.Health != pluginframeworkv1alpha1.PluginDisconnected || reg.Status.Replicas != 0 ||
+		reg.Status.DisconnectedSince == nil || reg.Status.ConnectedSince != nil {
+		t.Errorf("expected a disconnected plugin, got %+v", reg.Status)
+	}
+}
+
+func TestReconcileGarbageCollectsGonePlugins(t *testing.T) {
+	s, _ := startServer(t)
+	gone := &pluginframeworkv1alpha1.PluginRegistration{
+		ObjectMeta: metav1.ObjectMeta{Name: "gone-plugin", Namespace: testNamespace},
+		Status: pluginframeworkv1alpha1.PluginRegistrationStatus{
+			Health:            pluginframeworkv1alpha1.PluginDisconnected,
+			DisconnectedSince: &metav1.Time{Time: time.Now().Add(-2 * time.Hour)},
+		},
+	}
+	c := newFakeClient(t, gone)
+	r := New(c, s, testNamespace, WithDisconnectedTTL(time.Hour))
+	ctx := context.Background()
+
+	result, err := r.Reconcile(ctx, request("gone-plugin"))
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected no requeue, got %v", result.RequeueAfter)
+	}
+	err = c.Get(ctx, request("gone-plugin").NamespacedName, &pluginframeworkv1alpha1.PluginRegistration{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the object to be deleted, got %v", err)
+	}
+
+	// Unknown plugins get no object
+	if _, err := r.Reconcile(ctx, request("unknown-plugin")); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	list := &pluginframeworkv1alpha1.PluginRegistrationList{}
+	if err := c.List(ctx, list); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected no objects, got %d", len(list.Items))
+	}
+}
@@ -2,6 +2,9 @@ package registry
 
 import (
 	"errors"
+	"path"
+	"slices"
+	"strings"
 	"sync"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -12,6 +15,13 @@ type PluginProvider interface {
 	Name() string
 }
 
+// MethodProvider is implemented by plugins advertising the gRPC methods they serve.
+type MethodProvider interface {
+	PluginProvider
+	// Methods returns full method names ("/package.Service/Method").
+	Methods() []string
+}
+
 // Manager manages plugin registration and retrieval.
 type Manager struct {
 	plugins map[string]PluginProvider
@@ -91,3 +101,38 @@ func (m *Manager) Count() int {
 
 	return len(m.plugins)
 }
+
+// FindByService returns the names of the plugins serving a gRPC service,
+// given by its full name ("package.Service"), sorted by name.
+// Only plugins implementing MethodProvider are considered.
+func (m *Manager) FindByService(service string) []string {
+	service = strings.TrimPrefix(service, "/")
+	return m.find(func(method string) bool {
+		return path.Dir(strings.TrimPrefix(method, "/")) == service
+	})
+}
+
+// FindByMethod returns the names of the plugins serving a gRPC method,
+// given by its full name ("/package.Service/Method"), sorted by name.
+// Only plugins implementing MethodProvider are considered.
+func (m *Manager) FindByMethod(method string) []string {
+	return m.find(func(candidate string) bool {
+		return candidate == method
+	})
+}
+
+// find returns the names of the plugins with a method matching match.
+func (m *Manager) find(match func(method string) bool) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for name, plugin := range m.plugins {
+		provider, ok := plugin.(MethodProvider)
+		if ok && slices.ContainsFunc(provider.Methods(), match) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -241,3 +241,33 @@ func TestGetAllEmpty(t *testing.T) {
 		t.Errorf("expected empty map for new registry")
 	}
 }
+
+// MockMethodProvider is a test implementation of MethodProvider
+type MockMethodProvider struct {
+	MockPluginProvider
+	methods []string
+}
+
+func (m *MockMethodProvider) Methods() []string {
+	return m.methods
+}
+
+func TestFindByServiceAndMethod(t *testing.T) {
+	m := New()
+	m.Register("b", &MockMethodProvider{MockPluginProvider{name: "b"}, []string{"/pkg.Foo/Get", "/pkg.Bar/List"}})
+	m.Register("a", &MockMethodProvider{MockPluginProvider{name: "a"}, []string{"/pkg.Foo/Get"}})
+	m.Register("legacy", &MockPluginProvider{name: "legacy"})
+
+	if got := m.FindByService("pkg.Foo"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("expected [a b] for pkg.Foo, got %v", got)
+	}
+	if got := m.FindByService("pkg.Bar"); len(got) != 1 || got[0] != "b" {
+		t.Errorf("expected [b] for pkg.Bar, got %v", got)
+	}
+	if got := m.FindByMethod("/pkg.Bar/List"); len(got) != 1 || got[0] != "b" {
+		t.Errorf("expected [b] for /pkg.Bar/List, got %v", got)
+	}
+	if got := m.FindByMethod("/pkg.Foo/Delete"); len(got) != 0 {
+		t.Errorf("expected no plugin for /pkg.Foo/Delete, got %v", got)
+	}
+}
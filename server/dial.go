@@ -0,0 +1,427 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/guilhem/operator-plugin-framework/stream"
+)
+
+const (
+	// PluginNameAnnotation names the plugin served behind a discovered Service.
+	// The name of the Service is used when it is not set.
+	PluginNameAnnotation = "pluginframework.guilhem.github.io/plugin-name"
+
+	// PluginVersionLabel gives the version of the plugin served behind a discovered Service.
+	PluginVersionLabel = "app.kubernetes.io/version"
+
+	// DefaultDiscoveryInterval is how often Services are listed by default.
+	DefaultDiscoveryInterval = 30 * time.Second
+
+	// defaultDiscoveryPort is the name of the port dialed on discovered Services by default.
+	defaultDiscoveryPort = "grpc"
+
+	// Delays before admitting again a dialed plugin the server refused, while connected.
+	admitRetryInitialBackoff = time.Second
+	admitRetryMaxBackoff     = 30 * time.Second
+)
+
+// PluginEndpoint is a plugin served as a plain gRPC server, dialed by the operator
+// instead of connecting to it.
+type PluginEndpoint struct {
+	// Name of the plugin, as for plugins connecting to the server.
+	Name string
+
+	// Version of the plugin, reported in plugin events.
+	Version string
+
+	// Target is the gRPC target of the plugin, such as "dns:///widgets.plugins.svc:9000".
+	Target string
+
+	// DialOptions are the options of the connection to the plugin, insecure when empty.
+	// Set transport credentials there to use TLS.
+	DialOptions []grpc.DialOption
+}
+
+// ServiceDiscovery finds the plugins to dial among Kubernetes Services.
+type ServiceDiscovery struct {
+	// Reader lists the Services, such as the client of a controller-runtime manager.
+	Reader client.Reader
+
+	// Namespace of the Services, all namespaces when empty.
+	Namespace string
+
+	// Selector selects the Services of the plugins.
+	Selector labels.Selector
+
+	// PortName is the port dialed on the Services, "grpc" by default.
+	// Services without a port of this name are dialed on their first port.
+	PortName string
+
+	// Interval is how often Services are listed, DefaultDiscoveryInterval by default.
+	Interval time.Duration
+
+	// DialOptions are the options of the connections to the plugins, insecure when empty.
+	DialOptions []grpc.DialOption
+}
+
+// WithPluginEndpoints makes the server dial plugins at known addresses. Once connected,
+// they are listed, registered and called like the plugins connecting to the server.
+// The client interceptors and retry policies of WithStreamOptions apply to their calls,
+// but not idempotency keys, which plain gRPC servers do not know about.
+func WithPluginEndpoints(endpoints ...PluginEndpoint) ServerOption {
+	return func(s *Server) {
+		s.endpoints = append(s.endpoints, endpoints...)
+	}
+}
+
+// WithServiceDiscovery makes the server dial the plugins behind the Services selected by
+// discovery, named after PluginNameAnnotation or the Service. Plugins are dialed as with
+// WithPluginEndpoints, and released when their Service is gone. When several Services, or
+// a Service and an endpoint, give the same plugin name, only the endpoint or the first
+// Service by namespace and name is dialed.
+func WithServiceDiscovery(discovery ServiceDiscovery) ServerOption {
+	return func(s *Server) {
+		s.discovery = &discovery
+	}
+}
+
+// dialedPlugin is the connection of the server to a plugin it dialed.
+type dialedPlugin struct {
+	*grpc.ClientConn
+	endpoint PluginEndpoint
+
+	methodsMu sync.RWMutex
+	methods   []string
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Name returns the name of the plugin.
+// It makes a dialed plugin a registry.PluginProvider.
+func (p *dialedPlugin) Name() string {
+	return p.endpoint.Name
+}
+
+// Methods returns the full names of the methods found with server reflection,
+// none if the plugin does not serve it.
+func (p *dialedPlugin) Methods() []string {
+	p.methodsMu.RLock()
+	defer p.methodsMu.RUnlock()
+
+	return slices.Clone(p.methods)
+}
+
+// dialPlugins dials the endpoints of the plugins and the discovered Services until ctx
+// is done, then closes their connections.
+func (s *Server) dialPlugins(ctx context.Context) {
+	logger := log.FromContext(ctx)
+	dialed := make(map[string]*dialedPlugin)
+	defer func() {
+		for _, p := range dialed {
+			p.stop()
+			<-p.done
+		}
+	}()
+
+	// update dials the endpoints not dialed yet, or whose address changed, and stops
+	// dialing the others. Endpoints named like a previous one are skipped.
+	update := func(endpoints []PluginEndpoint) {
+		wanted := make(map[string]string, len(endpoints))
+		for _, endpoint := range endpoints {
+			if target, ok := wanted[endpoint.Name]; ok {
+				logger.Error(fmt.Errorf("plugin %s is already dialed at %s", endpoint.Name, target),
+					"Skipping plugin endpoint with a duplicate name", "plugin", endpoint.Name, "target", endpoint.Target)
+				continue
+			}
+			wanted[endpoint.Name] = endpoint.Target
+			if p, ok := dialed[endpoint.Name]; ok {
+				if p.endpoint.Target == endpoint.Target && p.endpoint.Version == endpoint.Version {
+					continue
+				}
+				p.stop()
+				<-p.done
+				delete(dialed, endpoint.Name)
+			}
+
+			p, err := s.streamManager.dialPlugin(ctx, endpoint)
+			if err != nil {
+				logger.Error(err, "Failed to dial plugin", "plugin", endpoint.Name, "target", endpoint.Target)
+				continue
+			}
+			dialed[endpoint.Name] = p
+		}
+		for name, p := range dialed {
+			if _, ok := wanted[name]; !ok {
+				p.stop()
+				<-p.done
+				delete(dialed, name)
+			}
+		}
+	}
+
+	if s.discovery == nil {
+		update(s.endpoints)
+		<-ctx.Done()
+		return
+	}
+
+	interval := s.discovery.Interval
+	if interval <= 0 {
+		interval = DefaultDiscoveryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		discovered, err := s.discovery.endpoints(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to discover plugin Services")
+		} else {
+			update(append(slices.Clone(s.endpoints), discovered...))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// endpoints returns the endpoints of the plugins behind the selected Services, ordered by
+// namespace and name of the Service, so that the same one wins when several share a name.
+func (d *ServiceDiscovery) endpoints(ctx context.Context) ([]PluginEndpoint, error) {
+	services := &corev1.ServiceList{}
+	opts := []client.ListOption{client.InNamespace(d.Namespace)}
+	if d.Selector != nil {
+		opts = append(opts, client.MatchingLabelsSelector{Selector: d.Selector})
+	}
+	if err := d.Reader.List(ctx, services, opts...); err != nil {
+		return nil, err
+	}
+
+	portName := d.PortName
+	if portName == "" {
+		portName = defaultDiscoveryPort
+	}
+
+	slices.SortFunc(services.Items, func(a, b corev1.Service) int {
+		return cmp.Or(strings.Compare(a.Namespace, b.Namespace), strings.Compare(a.Name, b.Name))
+	})
+
+	endpoints := make([]PluginEndpoint, 0, len(services.Items))
+	for _, svc := range services.Items {
+		if len(svc.Spec.Ports) == 0 {
+			continue
+		}
+		port := svc.Spec.Ports[0].Port
+		for _, p := range svc.Spec.Ports {
+			if p.Name == portName {
+				port = p.Port
+				break
+			}
+		}
+
+		name := svc.Annotations[PluginNameAnnotation]
+		if name == "" {
+			name = svc.Name
+		}
+		endpoints = append(endpoints, PluginEndpoint{
+			Name:        name,
+			Version:     svc.Labels[PluginVersionLabel],
+			Target:      fmt.Sprintf("dns:///%s.%s.svc:%d", svc.Name, svc.Namespace, port),
+			DialOptions: d.DialOptions,
+		})
+	}
+	return endpoints, nil
+}
+
+// dialPlugin creates the connection to the plugin of endpoint, and keeps the plugin
+// registered while the connection is ready, until ctx is done or the plugin is stopped.
+func (sm *StreamManager) dialPlugin(ctx context.Context, endpoint PluginEndpoint) (*dialedPlugin, error) {
+	opts := endpoint.DialOptions
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	// Connections are kept open to notice plugins going away
+	opts = append(slices.Clone(opts), grpc.WithIdleTimeout(0))
+	opts = append(opts, stream.DialOptions(sm.server.streamOptions...)...)
+
+	conn, err := grpc.NewClient(endpoint.Target, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	p := &dialedPlugin{
+		ClientConn: conn,
+		endpoint:   endpoint,
+		stop:       stop,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		defer func() { _ = conn.Close() }()
+		sm.watchDialedPlugin(ctx, p)
+	}()
+	return p, nil
+}
+
+// watchDialedPlugin admits a dialed plugin when its connection gets ready, and releases
+// it when the connection is lost, until ctx is done. A plugin the server refuses is
+// admitted again with backoff while its connection stays ready.
+func (sm *StreamManager) watchDialedPlugin(ctx context.Context, p *dialedPlugin) {
+	logger := log.FromContext(ctx).WithValues("plugin", p.Name(), "target", p.endpoint.Target)
+
+	var ms *ManagedStream
+	release := func() {
+		if current, err := sm.server.registry.Get(p.Name()); err == nil && current == p &&
+			sm.GetPluginStream(p.Name()) == ms {
+			sm.server.registry.Unregister(p.Name())
+		}
+		sm.releasePlugin(ms)
+		ms = nil
+	}
+	defer func() {
+		if ms != nil {
+			release()
+		}
+	}()
+
+	backoff := admitRetryInitialBackoff
+	p.Connect()
+	for {
+		retry := false
+		state := p.GetState()
+		switch {
+		case state == connectivity.Ready && ms == nil:
+			methods, err := reflectMethods(ctx, p.ClientConn)
+			if err != nil {
+				logger.V(1).Info("Plugin methods not found with server reflection", "error", err.Error())
+			}
+			p.methodsMu.Lock()
+			p.methods = methods
+			p.methodsMu.Unlock()
+
+			ms, err = sm.admitPlugin(ctx, p.Name())
+			if err != nil {
+				logger.Error(err, "Dialed plugin refused, retrying", "backoff", backoff.String())
+				retry = true
+				break
+			}
+			ms.setDialed(p)
+			sm.server.registry.Register(p.Name(), p)
+			sm.announce(ms, p.endpoint.Version)
+			logger.Info("Plugin dialed")
+
+		case state != connectivity.Ready && ms != nil:
+			logger.Info("Dialed plugin connection lost", "state", state.String())
+			release()
+		}
+
+		// Reconnect at once rather than on the next call
+		if state == connectivity.Idle {
+			p.Connect()
+		}
+		if !retry {
+			backoff = admitRetryInitialBackoff
+			if !p.WaitForStateChange(ctx, state) {
+				return
+			}
+			continue
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, backoff)
+		p.WaitForStateChange(waitCtx, state)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		backoff = min(2*backoff, admitRetryMaxBackoff)
+	}
+}
+
+// reflectMethods returns the methods served by conn, found with server reflection.
+// The reflection service itself is left out.
+func reflectMethods(ctx context.Context, conn grpc.ClientConnInterface) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	info, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = info.CloseSend() }()
+
+	ask := func(req *reflectionv1.ServerReflectionRequest) (*reflectionv1.ServerReflectionResponse, error) {
+		if err := info.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := info.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("server reflection error %d: %s", e.GetErrorCode(), e.GetErrorMessage())
+		}
+		return resp, nil
+	}
+
+	resp, err := ask(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		name := svc.GetName()
+		if name == reflectionv1.ServerReflection_ServiceDesc.ServiceName || name == "grpc.reflection.v1alpha.ServerReflection" {
+			continue
+		}
+
+		resp, err := ask(&reflectionv1.ServerReflectionRequest{
+			MessageRequest: &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+		})
+		if err != nil {
+			return methods, err
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return methods, err
+			}
+			for _, sd := range fd.GetService() {
+				full := sd.GetName()
+				if fd.GetPackage() != "" {
+					full = fd.GetPackage() + "." + full
+				}
+				if full != name {
+					continue
+				}
+				for _, md := range sd.GetMethod() {
+					methods = append(methods, "/"+full+"/"+md.GetName())
+				}
+			}
+		}
+	}
+	return methods, nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// PluginEventType is the kind of change reported by a PluginEvent.
+type PluginEventType string
+
+const (
+	// PluginConnected is reported when a plugin connects and no stream of the same name was.
+	PluginConnected PluginEventType = "Connected"
+	// PluginDisconnected is reported when the last stream of a plugin is closed.
+	PluginDisconnected PluginEventType = "Disconnected"
+	// PluginVersionChanged is reported when a plugin connects with another version than
+	// the one of the stream it replaces, for instance during a rolling update, or when an
+	// older replica of another version takes over from a stream that left.
+	PluginVersionChanged PluginEventType = "VersionChanged"
+)
+
+// PluginEvent is a change in the plugins connected to the server.
+type PluginEvent struct {
+	Type   PluginEventType
+	Plugin string
+
+	// Version is the version of the plugin, empty for disconnections.
+	Version string
+
+	// PreviousVersion is the version replaced, for PluginVersionChanged events.
+	PreviousVersion string
+}
+
+// maxPendingEvents bounds the events pending in the queue of a subscriber.
+const maxPendingEvents = 1024
+
+// eventQueue buffers the plugin events or notifications of one subscriber, so that a slow
+// subscriber never blocks plugin streams. Pending events with the same key are merged into
+// the latest one, which keeps the place of the first, and new keys are dropped once
+// maxPendingEvents are pending.
+type eventQueue[T any] struct {
+	key func(T) string
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]T
+	ready   chan struct{}
+}
+
+func newEventQueue[T any](key func(T) string) *eventQueue[T] {
+	return &eventQueue[T]{
+		key:     key,
+		pending: make(map[string]T),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// push adds evt to the queue. It reports false when the queue is full and evt was dropped.
+func (q *eventQueue[T]) push(evt T) bool {
+	k := q.key(evt)
+
+	q.mu.Lock()
+	if _, ok := q.pending[k]; !ok {
+		if len(q.order) >= maxPendingEvents {
+			q.mu.Unlock()
+			return false
+		}
+		q.order = append(q.order, k)
+	}
+	q.pending[k] = evt
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next returns the oldest event, waiting for one. It reports false when ctx is done.
+func (q *eventQueue[T]) next(ctx context.Context) (T, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.order) > 0 {
+			k := q.order[0]
+			q.order = q.order[1:]
+			evt := q.pending[k]
+			delete(q.pending, k)
+			q.mu.Unlock()
+			return evt, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, false
+		case <-q.ready:
+		}
+	}
+}
+
+// Source returns a controller-runtime source of the plugin events of the server, for
+// controllers reacting to plugins connecting, disconnecting or changing version:
+//
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.Widget{}).
+//		WatchesRawSource(srv.Source(handler.TypedEnqueueRequestsFromMapFunc(widgetsUsingPlugin))).
+//		Complete(r)
+//
+// Once started, the source first reports a PluginConnected event for each plugin already
+// connected. Events are passed to h as generic events, when all predicates accept them.
+// The events of a plugin not passed to h yet are merged into the latest one, so that a
+// source falling behind only reports the latest state of each plugin.
+func (s *Server) Source(h handler.TypedEventHandler[PluginEvent, reconcile.Request], predicates ...predicate.TypedPredicate[PluginEvent]) source.Source {
+	return &pluginEventSource{
+		streamManager: s.streamManager,
+		handler:       h,
+		predicates:    predicates,
+	}
+}
+
+// pluginEventSource is the source returned by Server.Source.
+type pluginEventSource struct {
+	streamManager *StreamManager
+	handler       handler.TypedEventHandler[PluginEvent, reconcile.Request]
+	predicates    []predicate.TypedPredicate[PluginEvent]
+}
+
+// Start passes the plugin events to the handler until ctx is done.
+func (ps *pluginEventSource) Start(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	events := ps.streamManager.subscribe()
+
+	go func() {
+		defer ps.streamManager.unsubscribe(events)
+
+		for {
+			evt, ok := events.next(ctx)
+			if !ok {
+				return
+			}
+
+			e := event.TypedGenericEvent[PluginEvent]{Object: evt}
+			if ps.accept(e) {
+				ps.handler.Generic(ctx, e, queue)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (ps *pluginEventSource) accept(e event.TypedGenericEvent[PluginEvent]) bool {
+	for _, p := range ps.predicates {
+		if !p.Generic(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ps *pluginEventSource) String() string {
+	return "plugin events"
+}
@@ -2,10 +2,16 @@ package server
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/guilhem/operator-plugin-framework/registry"
+	"github.com/guilhem/operator-plugin-framework/stream"
 )
 
 // StreamManager manages bidirectional plugin streams and handles automatic plugin registration.
@@ -21,15 +27,87 @@ type StreamManager struct {
 	maxMessageSize    int
 	mu                sync.Mutex
 	activeStreams     map[string]*ManagedStream
+	replicas          map[string][]*ManagedStream // streams by plugin name, the active one last
+
+	// announced holds the version of the plugins reported connected to the subscribers
+	// of plugin events, see Server.Source.
+	announced   map[string]string
+	subscribers map[*eventQueue[PluginEvent]]struct{}
+
+	// Notifications of the plugins, see Server.NotificationSource
+	notificationLimiters    map[string]*rate.Limiter
+	notificationSubscribers map[*eventQueue[PluginNotification]]struct{}
 }
 
 // ManagedStream represents a managed plugin stream with automatic registration.
 type ManagedStream struct {
 	pluginName  string
+	version     string
 	createdAt   time.Time
 	lastMessage time.Time
 	closeCh     chan struct{}
 	mu          sync.Mutex
+
+	// conn forwards calls to the plugin, nil for streams without RPC support
+	conn *stream.StreamManager
+
+	// dialed is the connection to a plugin dialed by the server, see WithPluginEndpoints
+	dialed *dialedPlugin
+
+	// announced is set once the stream is ready to be called, see StreamManager.announce
+	announced bool
+}
+
+// Conn returns the connection to the plugin, or nil if calls cannot be forwarded to it.
+func (ms *ManagedStream) Conn() *stream.StreamManager {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.conn
+}
+
+func (ms *ManagedStream) setConn(conn *stream.StreamManager) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.conn = conn
+}
+
+func (ms *ManagedStream) setDialed(p *dialedPlugin) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.dialed = p
+}
+
+// provider returns the connection of the plugin to keep in the registry of the server,
+// or nil if the plugin is not ready to be called.
+func (ms *ManagedStream) provider() registry.PluginProvider {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	switch {
+	case ms.conn != nil:
+		return ms.conn
+	case ms.dialed != nil:
+		return ms.dialed
+	}
+	return nil
+}
+
+// clientConn returns the connection calling the plugin, whether it connected to the
+// server or was dialed by it, or nil if calls cannot be forwarded to it.
+func (ms *ManagedStream) clientConn() grpc.ClientConnInterface {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	switch {
+	case ms.conn != nil:
+		return ms.conn
+	case ms.dialed != nil:
+		return ms.dialed
+	}
+	return nil
 }
 
 // NewStreamManager creates a new stream manager for the server.
@@ -40,6 +118,12 @@ func NewStreamManager(server *Server, opts ...StreamManagerOption) *StreamManage
 		streamTimeout:     5 * time.Minute,
 		maxMessageSize:    10 * 1024 * 1024, // 10MB default
 		activeStreams:     make(map[string]*ManagedStream),
+		replicas:          make(map[string][]*ManagedStream),
+		announced:         make(map[string]string),
+		subscribers:       make(map[*eventQueue[PluginEvent]]struct{}),
+
+		notificationLimiters:    make(map[string]*rate.Limiter),
+		notificationSubscribers: make(map[*eventQueue[PluginNotification]]struct{}),
 	}
 
 	for _, opt := range opts {
@@ -81,23 +165,29 @@ func WithMaxStreamMessageSize(size int) StreamManagerOption {
 
 // HandlePluginStream manages a new plugin stream connection.
 // This function:
-// 1. Waits for the first PluginRegister message
-// 2. Registers the plugin automatically
-// 3. Calls the connection handler
-// 4. Keeps the stream alive until closure or error
+// 1. Registers the plugin automatically
+// 2. Calls the connection handler
+// 3. Keeps the stream alive until closure or error
 //
-// This is meant to be called from the gRPC service implementation.
+// It returns ErrMaxConnectionsReached when the server is full.
 func (sm *StreamManager) HandlePluginStream(ctx context.Context, pluginName string) error {
-	logger := log.FromContext(ctx)
-
-	// Check connection limit before registering
-	sm.mu.Lock()
-	if len(sm.activeStreams) >= sm.server.maxConnections {
-		sm.mu.Unlock()
-		logger.Info("Max connections reached, rejecting new plugin", "plugin", pluginName)
-		return ErrMaxConnectionsReached
+	ms, err := sm.admitPlugin(ctx, pluginName)
+	if err != nil {
+		return err
 	}
-	sm.mu.Unlock()
+	sm.announce(ms, "")
+
+	// Keep stream alive until context cancellation or error
+	<-ctx.Done()
+
+	sm.releasePlugin(ms)
+	return ctx.Err()
+}
+
+// admitPlugin registers a connecting plugin and calls the connection handler.
+// It returns ErrMaxConnectionsReached when the server is full.
+func (sm *StreamManager) admitPlugin(ctx context.Context, pluginName string) (*ManagedStream, error) {
+	logger := log.FromContext(ctx)
 
 	// Create managed stream
 	ms := &ManagedStream{
@@ -107,9 +197,15 @@ func (sm *StreamManager) HandlePluginStream(ctx context.Context, pluginName stri
 		closeCh:     make(chan struct{}),
 	}
 
-	// Register the plugin
+	// Check the connection limit and register the plugin at once
 	sm.mu.Lock()
+	if sm.streamCount() >= sm.server.maxConnections {
+		sm.mu.Unlock()
+		logger.Info("Max connections reached, rejecting new plugin", "plugin", pluginName)
+		return nil, ErrMaxConnectionsReached
+	}
 	sm.activeStreams[pluginName] = ms
+	sm.replicas[pluginName] = append(sm.replicas[pluginName], ms)
 	sm.mu.Unlock()
 
 	logger.Info("Plugin registered", "plugin", pluginName)
@@ -117,33 +213,131 @@ func (sm *StreamManager) HandlePluginStream(ctx context.Context, pluginName stri
 	// Call connection handler
 	if err := sm.connectionHandler.OnPluginConnect(pluginName); err != nil {
 		logger.Error(err, "Connection handler failed", "plugin", pluginName)
-		sm.unregisterPlugin(pluginName)
-		return err
+		sm.unregisterPlugin(ms)
+		return nil, err
 	}
 
-	// Keep stream alive until context cancellation or error
-	<-ctx.Done()
-
-	// Disconnect handler
-	sm.unregisterPlugin(pluginName)
-	_ = sm.connectionHandler.OnPluginDisconnect(pluginName)
+	return ms, nil
+}
 
-	return ctx.Err()
+// releasePlugin unregisters a disconnected plugin and calls the connection handler.
+func (sm *StreamManager) releasePlugin(ms *ManagedStream) {
+	sm.unregisterPlugin(ms)
+	_ = sm.connectionHandler.OnPluginDisconnect(ms.pluginName)
 }
 
-// unregisterPlugin safely removes a plugin from tracking.
-func (sm *StreamManager) unregisterPlugin(pluginName string) {
+// unregisterPlugin safely removes a plugin stream from tracking.
+// When the active stream of a plugin leaves, the latest of its other replicas takes over.
+func (sm *StreamManager) unregisterPlugin(ms *ManagedStream) {
 	logger := log.Log
 
-	// Remove from active streams
 	sm.mu.Lock()
-	if ms, exists := sm.activeStreams[pluginName]; exists {
-		close(ms.closeCh)
-		delete(sm.activeStreams, pluginName)
+	replicas := slices.DeleteFunc(sm.replicas[ms.pluginName], func(r *ManagedStream) bool { return r == ms })
+	if len(replicas) == 0 {
+		delete(sm.replicas, ms.pluginName)
+	} else {
+		sm.replicas[ms.pluginName] = replicas
+	}
+	if sm.activeStreams[ms.pluginName] == ms {
+		delete(sm.activeStreams, ms.pluginName)
+		if len(replicas) > 0 {
+			sm.promote(replicas[len(replicas)-1])
+		}
+	}
+	if _, active := sm.activeStreams[ms.pluginName]; !active {
+		delete(sm.notificationLimiters, ms.pluginName)
+		if _, announced := sm.announced[ms.pluginName]; announced {
+			delete(sm.announced, ms.pluginName)
+			sm.publish(PluginEvent{Type: PluginDisconnected, Plugin: ms.pluginName})
+		}
 	}
 	sm.mu.Unlock()
+	close(ms.closeCh)
+
+	logger.Info("Plugin unregistered", "plugin", ms.pluginName)
+}
+
+// promote makes a replica the active stream of its plugin, in place of the one that left.
+// sm.mu must be held.
+func (sm *StreamManager) promote(ms *ManagedStream) {
+	sm.activeStreams[ms.pluginName] = ms
 
-	logger.Info("Plugin unregistered", "plugin", pluginName)
+	// A replica still registering is announced once ready
+	ms.mu.Lock()
+	announced, version := ms.announced, ms.version
+	ms.mu.Unlock()
+	if !announced {
+		return
+	}
+	if provider := ms.provider(); provider != nil {
+		sm.server.registry.Register(ms.pluginName, provider)
+	}
+	previous, ok := sm.announced[ms.pluginName]
+	sm.announced[ms.pluginName] = version
+	switch {
+	case !ok:
+		sm.publish(PluginEvent{Type: PluginConnected, Plugin: ms.pluginName, Version: version})
+	case previous != version:
+		sm.publish(PluginEvent{Type: PluginVersionChanged, Plugin: ms.pluginName, Version: version, PreviousVersion: previous})
+	}
+	log.Log.Info("Plugin replica promoted", "plugin", ms.pluginName, "version", version)
+}
+
+// announce reports a plugin ready to be called at the given version to the subscribers
+// of plugin events: as connected, or as changing version if another version was connected.
+func (sm *StreamManager) announce(ms *ManagedStream, version string) {
+	ms.mu.Lock()
+	ms.version = version
+	ms.announced = true
+	ms.mu.Unlock()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.activeStreams[ms.pluginName] != ms {
+		return
+	}
+
+	previous, announced := sm.announced[ms.pluginName]
+	sm.announced[ms.pluginName] = version
+	switch {
+	case !announced:
+		sm.publish(PluginEvent{Type: PluginConnected, Plugin: ms.pluginName, Version: version})
+	case previous != version:
+		sm.publish(PluginEvent{Type: PluginVersionChanged, Plugin: ms.pluginName, Version: version, PreviousVersion: previous})
+	}
+}
+
+// publish passes evt to the subscribers of plugin events. sm.mu must be held.
+func (sm *StreamManager) publish(evt PluginEvent) {
+	for events := range sm.subscribers {
+		if !events.push(evt) {
+			log.Log.Info("Dropping plugin event, too many pending", "plugin", evt.Plugin, "event", evt.Type)
+		}
+	}
+}
+
+// subscribe returns a queue of the plugin events from now on, starting with a
+// PluginConnected event for each plugin already announced.
+func (sm *StreamManager) subscribe() *eventQueue[PluginEvent] {
+	events := newEventQueue(func(evt PluginEvent) string { return evt.Plugin })
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for name, version := range sm.announced {
+		events.push(PluginEvent{Type: PluginConnected, Plugin: name, Version: version})
+	}
+	sm.subscribers[events] = struct{}{}
+	return events
+}
+
+// unsubscribe stops passing plugin events to events.
+func (sm *StreamManager) unsubscribe(events *eventQueue[PluginEvent]) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	delete(sm.subscribers, events)
 }
 
 // IsPluginConnected checks if a plugin has an active managed stream.
@@ -180,7 +374,17 @@ func (sm *StreamManager) ConnectionCount() int {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	return len(sm.activeStreams)
+	return sm.streamCount()
+}
+
+// streamCount returns the number of streams of all the plugins, replicas included.
+// The caller must hold sm.mu.
+func (sm *StreamManager) streamCount() int {
+	count := 0
+	for _, replicas := range sm.replicas {
+		count += len(replicas)
+	}
+	return count
 }
 
 // UpdateLastMessageTime updates the last message timestamp for a plugin.
@@ -209,17 +413,32 @@ func (sm *StreamManager) GetPluginInfo(pluginName string) *PluginStreamInfo {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	return &PluginStreamInfo{
+	info := &PluginStreamInfo{
 		Name:          ms.pluginName,
+		Version:       ms.version,
+		Replicas:      len(sm.replicas[pluginName]),
 		ConnectedAt:   ms.createdAt,
 		LastMessageAt: ms.lastMessage,
 		Uptime:        time.Since(ms.createdAt),
 	}
+	if ms.dialed != nil {
+		info.Methods = ms.dialed.Methods()
+	}
+	if ms.conn != nil {
+		info.Methods = ms.conn.Methods()
+		if last := ms.conn.LastMessageTime(); last.After(info.LastMessageAt) {
+			info.LastMessageAt = last
+		}
+	}
+	return info
 }
 
 // PluginStreamInfo contains information about a plugin's stream connection.
 type PluginStreamInfo struct {
 	Name          string
+	Version       string
+	Replicas      int      // Streams of the plugin, several when it runs several replicas
+	Methods       []string // Methods advertised by the plugin
 	ConnectedAt   time.Time
 	LastMessageAt time.Time
 	Uptime        time.Duration
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+	"github.com/guilhem/operator-plugin-framework/stream"
+)
+
+const (
+	// DefaultNotificationRate and DefaultNotificationBurst limit the notifications
+	// accepted from each plugin, see WithNotificationRateLimit.
+	DefaultNotificationRate  rate.Limit = 10
+	DefaultNotificationBurst            = 50
+)
+
+// PluginNotification is a request of a plugin to reconcile a Kubernetes object,
+// sent with stream.PluginStreamClient.Notify.
+type PluginNotification struct {
+	Plugin string
+	Key    types.NamespacedName
+	Reason string
+}
+
+// NotificationAuthorizer decides whether a plugin may have an object reconciled.
+// Notifications it refuses with an error are dropped. It runs on the goroutine reading
+// the stream of the plugin, which it blocks until it returns.
+type NotificationAuthorizer func(ctx context.Context, notification PluginNotification) error
+
+// WithNotificationAuthorizer sets the authorization of the notifications of plugins.
+// By default, plugins may have any object reconciled.
+func WithNotificationAuthorizer(authorizer NotificationAuthorizer) ServerOption {
+	return func(s *Server) {
+		s.authorizeNotification = authorizer
+	}
+}
+
+// WithNotificationRateLimit limits the notifications accepted from each plugin to limit
+// per second, with bursts of burst notifications. Notifications over the limit are dropped.
+func WithNotificationRateLimit(limit rate.Limit, burst int) ServerOption {
+	return func(s *Server) {
+		s.notificationLimit = limit
+		s.notificationBurst = burst
+	}
+}
+
+// NotificationSource returns a controller-runtime source of reconcile requests for the
+// objects named by the notifications of plugins, once rate limited and authorized:
+//
+//	ctrl.NewControllerManagedBy(mgr).
+//		For(&v1.Widget{}).
+//		WatchesRawSource(srv.NotificationSource()).
+//		Complete(r)
+//
+// Notifications are only accepted while a source is started. The predicates filter the
+// notifications, for instance to keep those of some plugins. Notifications of an object
+// by a plugin not handled yet are merged, and notifications are dropped while too many
+// are pending.
+func (s *Server) NotificationSource(predicates ...predicate.TypedPredicate[PluginNotification]) source.Source {
+	return &notificationSource{
+		streamManager: s.streamManager,
+		predicates:    predicates,
+	}
+}
+
+// notificationSource is the source returned by Server.NotificationSource.
+type notificationSource struct {
+	streamManager *StreamManager
+	predicates    []predicate.TypedPredicate[PluginNotification]
+}
+
+// Start enqueues requests for the notified objects until ctx is done.
+func (ns *notificationSource) Start(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	notifications := ns.streamManager.subscribeNotifications()
+
+	go func() {
+		defer ns.streamManager.unsubscribeNotifications(notifications)
+
+		for {
+			notification, ok := notifications.next(ctx)
+			if !ok {
+				return
+			}
+			if ns.accept(notification) {
+				queue.Add(reconcile.Request{NamespacedName: notification.Key})
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (ns *notificationSource) accept(notification PluginNotification) bool {
+	e := event.TypedGenericEvent[PluginNotification]{Object: notification}
+	for _, p := range ns.predicates {
+		if !p.Generic(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ns *notificationSource) String() string {
+	return "plugin notifications"
+}
+
+// notify passes a notification of a plugin to the notification sources, unless it is
+// over the rate limit of the plugin or refused by the authorizer.
+func (sm *StreamManager) notify(ctx context.Context, msg *pluginframeworkv1.PluginNotification) {
+	plugin, _ := stream.PluginFromContext(ctx)
+	notification := PluginNotification{
+		Plugin: plugin.GetPluginName(),
+		Key:    types.NamespacedName{Namespace: msg.GetNamespace(), Name: msg.GetName()},
+		Reason: msg.GetReason(),
+	}
+	logger := log.Log.WithValues("plugin", notification.Plugin, "object", notification.Key, "reason", notification.Reason)
+
+	if !sm.notificationLimiter(notification.Plugin).Allow() {
+		logger.Info("Dropping plugin notification over the rate limit")
+		return
+	}
+	if sm.server.authorizeNotification != nil {
+		if err := sm.server.authorizeNotification(ctx, notification); err != nil {
+			logger.Info("Dropping unauthorized plugin notification", "error", err.Error())
+			return
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for notifications := range sm.notificationSubscribers {
+		if !notifications.push(notification) {
+			logger.Info("Dropping plugin notification, too many pending")
+		}
+	}
+}
+
+// notificationLimiter returns the rate limiter of the notifications of a plugin, kept
+// while the plugin is connected.
+func (sm *StreamManager) notificationLimiter(pluginName string) *rate.Limiter {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	limiter, ok := sm.notificationLimiters[pluginName]
+	if !ok {
+		limiter = rate.NewLimiter(sm.server.notificationLimit, sm.server.notificationBurst)
+		sm.notificationLimiters[pluginName] = limiter
+	}
+	return limiter
+}
+
+// subscribeNotifications returns a queue of the notifications accepted from now on.
+func (sm *StreamManager) subscribeNotifications() *eventQueue[PluginNotification] {
+	notifications := newEventQueue(func(n PluginNotification) string { return n.Plugin + "/" + n.Key.String() })
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.notificationSubscribers[notifications] = struct{}{}
+	return notifications
+}
+
+// unsubscribeNotifications stops passing notifications to notifications.
+func (sm *StreamManager) unsubscribeNotifications(notifications *eventQueue[PluginNotification]) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	delete(sm.notificationSubscribers, notifications)
+}
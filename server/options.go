@@ -1,11 +1,35 @@
 package server
 
+import (
+	"time"
+
+	"github.com/guilhem/operator-plugin-framework/stream"
+)
+
 // ServerOption is a functional option for Server configuration
 type ServerOption func(*Server)
 
-// WithMaxConnections sets the maximum number of concurrent plugin connections
+// WithMaxConnections sets the maximum number of concurrent plugin connections.
+// Each replica of a plugin counts as a connection.
 func WithMaxConnections(max int) ServerOption {
 	return func(s *Server) {
 		s.maxConnections = max
 	}
 }
+
+// WithStreamOptions sets the options of the plugin streams, such as retry policies
+// and payload compression.
+func WithStreamOptions(opts ...stream.Option) ServerOption {
+	return func(s *Server) {
+		s.streamOptions = append(s.streamOptions, opts...)
+	}
+}
+
+// WithSessionResumption lets plugins that lost their stream resume their session within
+// gracePeriod (stream.DefaultResumeGracePeriod if zero): calls waiting for a plugin then
+// get their answer once it reconnected, instead of failing with Unavailable.
+func WithSessionResumption(gracePeriod time.Duration) ServerOption {
+	return func(s *Server) {
+		s.streamOptions = append(s.streamOptions, stream.WithSessionStore(stream.NewSessionStore(gracePeriod)))
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"reflect"
+	"slices"
 	"sync"
 
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
 	"github.com/guilhem/operator-plugin-framework/registry"
+	"github.com/guilhem/operator-plugin-framework/stream"
 )
 
 // Server manages bidirectional plugin connections via gRPC.
@@ -21,12 +25,28 @@ type Server struct {
 	maxConnections int
 	registry       *registry.Manager
 	streamManager  *StreamManager
+	streamOptions  []stream.Option
+	callbacks      []string // names of the callback services, see RegisterService
 	mu             sync.RWMutex
 	grpcServer     *grpc.Server
 	listener       net.Listener
 	isRunning      bool
+
+	// Notifications of the plugins, see NotificationSource
+	notificationLimit     rate.Limit
+	notificationBurst     int
+	authorizeNotification NotificationAuthorizer
+
+	// Plugins dialed by the server, see WithPluginEndpoints and WithServiceDiscovery
+	endpoints []PluginEndpoint
+	discovery *ServiceDiscovery
+
+	// Plugin executables launched by the server, see WithSubprocessPlugins
+	subprocesses []SubprocessPlugin
 }
 
+var _ grpc.ServiceRegistrar = (*Server)(nil)
+
 // New creates a new plugin server.
 // Authentication is handled by kube-rbac-proxy sidecar.
 // Plugins are automatically registered on connection via HandlePluginStream.
@@ -35,6 +55,9 @@ func New(addr string, opts ...ServerOption) *Server {
 		addr:           addr,
 		maxConnections: 100,
 		registry:       registry.New(),
+
+		notificationLimit: DefaultNotificationRate,
+		notificationBurst: DefaultNotificationBurst,
 	}
 
 	for _, opt := range opts {
@@ -62,6 +85,50 @@ func (s *Server) IsPluginConnected(name string) bool {
 	return s.streamManager.IsPluginConnected(name)
 }
 
+// PluginConn returns the connection forwarding calls to a connected plugin.
+// Generated gRPC clients can be created on it, for instance
+// NewMyServiceClient(conn). It returns ErrPluginNotFound when the plugin is not connected.
+func (s *Server) PluginConn(name string) (grpc.ClientConnInterface, error) {
+	ms := s.streamManager.GetPluginStream(name)
+	if ms == nil {
+		return nil, ErrPluginNotFound
+	}
+	conn := ms.clientConn()
+	if conn == nil {
+		return nil, ErrPluginNotFound
+	}
+	return conn, nil
+}
+
+// RegisterService registers a callback service of the operator, which plugins call over
+// their stream (see stream.WithCallbackService), so that generated Register*Server
+// functions can be used with a Server:
+//
+//	pb.RegisterStatusReporterServer(srv, &statusReporter{})
+//
+// As with grpc.Server, it must be called before Start, and panics when impl does not
+// implement the service, or when the service is already registered.
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		panic("server: Server.RegisterService after Server.Start")
+	}
+	if impl != nil && desc.HandlerType != nil {
+		ht := reflect.TypeOf(desc.HandlerType).Elem()
+		if st := reflect.TypeOf(impl); !st.Implements(ht) {
+			panic(fmt.Sprintf("server: Server.RegisterService found the handler of type %v that does not satisfy %v", st, ht))
+		}
+	}
+	if slices.Contains(s.callbacks, desc.ServiceName) {
+		panic(fmt.Sprintf("server: Server.RegisterService found duplicate service registration for %q", desc.ServiceName))
+	}
+
+	s.callbacks = append(s.callbacks, desc.ServiceName)
+	s.streamOptions = append(s.streamOptions, stream.WithCallbackService(desc, impl))
+}
+
 // GetStreamManager returns the StreamManager for handling plugin connections.
 // This is used to call HandlePluginStream from your gRPC service implementation.
 func (s *Server) GetStreamManager() *StreamManager {
@@ -117,6 +184,39 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Dial the plugins served at known addresses, until the server stops
+	if len(s.endpoints) > 0 || s.discovery != nil {
+		dialCtx, stopDialing := context.WithCancel(ctx)
+		dialing := make(chan struct{})
+		go func() {
+			defer close(dialing)
+			s.dialPlugins(dialCtx)
+		}()
+		defer func() {
+			stopDialing()
+			<-dialing
+		}()
+	}
+
+	// Launch the plugin executables, until the server stops
+	if len(s.subprocesses) > 0 {
+		l, err := s.newLauncher()
+		if err != nil {
+			s.Stop()
+			return err
+		}
+		launchCtx, stopLaunching := context.WithCancel(ctx)
+		launching := make(chan struct{})
+		go func() {
+			defer close(launching)
+			l.run(launchCtx)
+		}()
+		defer func() {
+			stopLaunching()
+			<-launching
+		}()
+	}
+
 	// Block on context cancellation
 	select {
 	case <-ctx.Done():
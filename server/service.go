@@ -1,12 +1,16 @@
 package server
 
 import (
+	"errors"
+	"io"
+
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+	"github.com/guilhem/operator-plugin-framework/stream"
 )
 
 // PluginFrameworkServiceServerImpl implements the generated PluginFrameworkServiceServer.
@@ -27,51 +31,80 @@ func NewPluginFrameworkServiceServer(server *Server) *PluginFrameworkServiceServ
 // PluginStream implements the bidirectional streaming RPC for plugin communication.
 // This method handles the complete plugin lifecycle:
 // 1. Receives PluginRegister message
-// 2. Registers the plugin automatically
-// 3. Manages the bidirectional stream for RPC calls and responses
-func (s *PluginFrameworkServiceServerImpl) PluginStream(stream grpc.BidiStreamingServer[pluginframeworkv1.PluginStreamMessage, pluginframeworkv1.PluginStreamMessage]) error {
-	logger := log.FromContext(stream.Context())
+// 2. Registers the plugin automatically, or rejects it with the reason in a PluginRegisterAck
+// 3. Forwards RPC calls to the plugin until the stream ends (see Server.PluginConn)
+//
+// With WithSessionResumption, a plugin reconnecting with its resume token continues
+// its session on the new stream, going through the same steps.
+func (s *PluginFrameworkServiceServerImpl) PluginStream(pluginStream grpc.BidiStreamingServer[pluginframeworkv1.PluginStreamMessage, pluginframeworkv1.PluginStreamMessage]) error {
+	ctx := pluginStream.Context()
+	logger := log.FromContext(ctx)
 
-	// Step 1: Wait for plugin registration
-	msg, err := stream.Recv()
-	if err != nil {
-		logger.Error(err, "Failed to receive initial message from plugin")
-		return status.Errorf(codes.InvalidArgument, "failed to receive plugin registration: %v", err)
-	}
+	// Step 1 and 2: receive the registration and admit the plugin
+	var ms *ManagedStream
+	var rejection error
+	admit := func(register *pluginframeworkv1.PluginRegister) (err error) {
+		defer func() { rejection = err }()
 
-	// Validate that first message is PluginRegister
-	register := msg.GetRegister()
-	if register == nil {
-		logger.Error(nil, "First message must be PluginRegister", "payload", msg.GetPayload())
-		return status.Errorf(codes.InvalidArgument, "first message must be PluginRegister")
-	}
+		pluginName := register.GetName()
+		if pluginName == "" {
+			return status.Errorf(codes.InvalidArgument, "plugin name cannot be empty")
+		}
 
-	pluginName := register.GetName()
-	if pluginName == "" {
-		return status.Errorf(codes.InvalidArgument, "plugin name cannot be empty")
-	}
+		logger.Info("Plugin attempting to connect", "plugin", pluginName, "version", register.GetVersion())
+
+		// A launched plugin may only register under its own name
+		if launched, ok := ctx.Value(subprocessKey{}).(string); ok && launched != pluginName {
+			return status.Errorf(codes.PermissionDenied, "plugin launched as %s cannot register as %s", launched, pluginName)
+		}
 
-	logger.Info("Plugin attempting to connect", "plugin", pluginName, "version", register.GetVersion())
+		ms, err = s.server.streamManager.admitPlugin(ctx, pluginName)
+		switch {
+		case errors.Is(err, ErrMaxConnectionsReached):
+			return status.Error(codes.ResourceExhausted, err.Error())
+		case err != nil:
+			return status.Errorf(codes.FailedPrecondition, "plugin %s refused: %v", pluginName, err)
+		}
+		return nil
+	}
 
-	// Step 2: Use the existing StreamManager to handle the connection
-	// This automatically registers the plugin and manages the connection lifecycle
-	err = s.server.HandlePluginStream(stream.Context(), pluginName)
+	opts := append(append([]stream.Option{}, s.server.streamOptions...),
+		stream.WithAdmission(admit), stream.WithNotificationHandler(s.server.streamManager.notify))
+	conn, err := stream.NewStreamManager(pluginStream, opts...)
 	if err != nil {
-		logger.Error(err, "Failed to handle plugin stream", "plugin", pluginName)
-		return status.Errorf(codes.Internal, "failed to handle plugin stream: %v", err)
+		if rejection != nil {
+			logger.Info("Plugin rejected", "reason", rejection.Error())
+			return rejection
+		}
+		if ms != nil {
+			s.server.streamManager.releasePlugin(ms)
+		}
+		logger.Error(err, "Failed to register plugin")
+		return status.Errorf(codes.InvalidArgument, "failed to register plugin: %v", err)
 	}
+	defer s.server.streamManager.releasePlugin(ms)
+	ms.setConn(conn)
 
-	// Step 3: Keep the stream alive for bidirectional communication
-	// The StreamManager handles the lifecycle, so we just need to keep the gRPC stream open
-	// In a real implementation, you would integrate with the stream package's StreamManager
-	// to handle RPC calls and responses
+	// Make the plugin and its methods available in the registry while connected
+	pluginName := conn.GetPluginName()
+	s.server.registry.Register(pluginName, conn)
+	defer func() {
+		// A resumed session is registered again by the stream resuming it
+		if current, err := s.server.registry.Get(pluginName); err == nil && current == conn &&
+			s.server.streamManager.GetPluginStream(pluginName) == ms {
+			s.server.registry.Unregister(pluginName)
+		}
+	}()
+	s.server.streamManager.announce(ms, conn.GetPluginVersion())
 
-	logger.Info("Plugin stream established", "plugin", pluginName)
+	logger.Info("Plugin stream established", "plugin", pluginName, "session", conn.GetSessionID())
 
-	// For now, we keep the stream open until context cancellation
-	// Future enhancement: integrate with stream.StreamManager for full RPC support
-	<-stream.Context().Done()
+	// Step 3: forward calls until the plugin disconnects
+	err = conn.ListenForMessages(ctx)
+	logger.Info("Plugin stream closed", "plugin", pluginName, "reason", err.Error())
 
-	logger.Info("Plugin stream closed", "plugin", pluginName)
-	return stream.Context().Err()
+	if ctx.Err() != nil || errors.Is(err, io.EOF) {
+		return ctx.Err()
+	}
+	return status.Errorf(codes.Unavailable, "plugin stream failed: %v", err)
 }
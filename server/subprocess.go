@@ -0,0 +1,268 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+	"github.com/guilhem/operator-plugin-framework/stream"
+)
+
+const (
+	defaultRestartInitialBackoff = time.Second
+	defaultRestartMaxBackoff     = time.Minute
+
+	// subprocessStopTimeout is how long a plugin process has to exit once asked to.
+	subprocessStopTimeout = 5 * time.Second
+)
+
+// SubprocessPlugin is a plugin executable launched and supervised by the server.
+// The process connects back to the server over a private unix socket (see
+// client.NewSubprocess), and is restarted with exponential backoff when it exits.
+// Its standard output and error are forwarded to the logger of the server.
+type SubprocessPlugin struct {
+	// Name of the plugin, which the process must register with.
+	Name string
+
+	// Path of the executable, and its arguments.
+	Path string
+	Args []string
+
+	// Env is added to the environment of the server given to the process ("KEY=value").
+	Env []string
+
+	// InitialBackoff is the delay before the first restart. Defaults to 1s.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between restarts, and resets it when the process ran
+	// for longer. Defaults to 1m.
+	MaxBackoff time.Duration
+}
+
+// WithSubprocessPlugins makes the server launch plugin executables and keep them
+// running. Once connected, they are listed, registered and called like the plugins
+// connecting to the server.
+func WithSubprocessPlugins(plugins ...SubprocessPlugin) ServerOption {
+	return func(s *Server) {
+		s.subprocesses = append(s.subprocesses, plugins...)
+	}
+}
+
+// subprocessKey is the context key of the name of the plugin a stream was authenticated for.
+type subprocessKey struct{}
+
+// launcher runs the subprocess plugins of a server, and serves their streams on a
+// private unix socket.
+type launcher struct {
+	server     *Server
+	dir        string
+	addr       string
+	grpcServer *grpc.Server
+
+	mu     sync.Mutex
+	tokens map[string]string // plugin name by one-time token
+}
+
+// newLauncher creates the private socket of the subprocess plugins and starts serving it.
+func (s *Server) newLauncher() (*launcher, error) {
+	dir, err := os.MkdirTemp("", "plugin-framework-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin socket directory: %w", err)
+	}
+	socket := filepath.Join(dir, "plugins.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to listen on plugin socket: %w", err)
+	}
+
+	l := &launcher{
+		server: s,
+		dir:    dir,
+		addr:   "unix://" + socket,
+		tokens: make(map[string]string),
+	}
+	l.grpcServer = grpc.NewServer(grpc.StreamInterceptor(l.authenticate))
+	pluginframeworkv1.RegisterPluginFrameworkServiceServer(l.grpcServer, NewPluginFrameworkServiceServer(s))
+	go func() { _ = l.grpcServer.Serve(lis) }()
+
+	return l, nil
+}
+
+// run keeps the subprocess plugins running until ctx is done, then stops them and
+// the private socket.
+func (l *launcher) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, plugin := range l.server.subprocesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.supervise(ctx, plugin)
+		}()
+	}
+	wg.Wait()
+
+	l.grpcServer.Stop()
+	_ = os.RemoveAll(l.dir)
+}
+
+// supervise runs a plugin process, and restarts it with backoff when it exits,
+// until ctx is done.
+func (l *launcher) supervise(ctx context.Context, plugin SubprocessPlugin) {
+	logger := log.FromContext(ctx).WithValues("plugin", plugin.Name)
+
+	initial, maxBackoff := plugin.InitialBackoff, plugin.MaxBackoff
+	if initial <= 0 {
+		initial = defaultRestartInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRestartMaxBackoff
+	}
+
+	backoff := initial
+	for {
+		started := time.Now()
+		err := l.runProcess(ctx, plugin, logger)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > maxBackoff {
+			backoff = initial
+		}
+		logger.Error(err, "Plugin process exited, restarting", "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// runProcess runs a plugin process with a new token until it exits, or until ctx is done
+// and the process stopped.
+func (l *launcher) runProcess(ctx context.Context, plugin SubprocessPlugin, logger logr.Logger) error {
+	token := l.issueToken(plugin.Name)
+	defer l.revokeToken(token)
+
+	cmd := exec.CommandContext(ctx, plugin.Path, plugin.Args...)
+	cmd.Env = append(append(os.Environ(), plugin.Env...),
+		stream.SubprocessCookieEnv+"="+stream.SubprocessCookie,
+		stream.SubprocessAddrEnv+"="+l.addr,
+		stream.SubprocessNameEnv+"="+plugin.Name,
+		stream.SubprocessTokenEnv+"="+token,
+	)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = subprocessStopTimeout
+
+	stdout, stopStdout := forwardOutput(logger.WithValues("stream", "stdout"))
+	defer stopStdout()
+	stderr, stopStderr := forwardOutput(logger.WithValues("stream", "stderr"))
+	defer stopStderr()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", plugin.Name, err)
+	}
+	logger.Info("Plugin process started", "pid", cmd.Process.Pid)
+	return cmd.Wait()
+}
+
+// forwardOutput returns a writer logging each line written to it, and a function
+// waiting for the last line to be logged once the writer is no longer used.
+func forwardOutput(logger logr.Logger) (io.Writer, func()) {
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			logger.Info(scanner.Text())
+		}
+		_, _ = io.Copy(io.Discard, r)
+	}()
+	return w, func() {
+		_ = w.Close()
+		<-done
+	}
+}
+
+// issueToken returns a new one-time token for a process of a plugin.
+func (l *launcher) issueToken(pluginName string) string {
+	token := randomToken()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens[token] = pluginName
+	return token
+}
+
+// randomToken returns a random hex token.
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// revokeToken invalidates token if it was not used.
+func (l *launcher) revokeToken(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.tokens, token)
+}
+
+// authenticate accepts the streams of the processes presenting a token not used yet,
+// which can then only register the plugin the token was issued for.
+func (l *launcher) authenticate(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ss.Context()); ok {
+		for _, v := range md.Get("authorization") {
+			token = strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+
+	l.mu.Lock()
+	pluginName, ok := l.tokens[token]
+	delete(l.tokens, token)
+	l.mu.Unlock()
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid or already used plugin token")
+	}
+
+	ctx := context.WithValue(ss.Context(), subprocessKey{}, pluginName)
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a stream carrying the plugin its token was issued for.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,416 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// pluginRequestPrefix starts the request IDs of the calls made by the plugin, so that
+// they never collide with the request IDs of the operator on the same stream.
+const pluginRequestPrefix = "p"
+
+// isPluginRequest reports whether requestID belongs to a call made by the plugin.
+func isPluginRequest(requestID string) bool {
+	return strings.HasPrefix(requestID, pluginRequestPrefix)
+}
+
+// caller makes RPC calls to the peer of a stream and correlates their responses.
+// The operator calls the services of the plugin with it, and the plugin those of the
+// operator (see PluginStreamClient.OperatorConn).
+type caller struct {
+	// Single writer of the stream
+	sender *sender
+
+	// Peer named in errors and logs, such as "plugin my-plugin"
+	peer string
+
+	// Source of the request IDs, unique on the stream, and the prefix they start with
+	lastRequestID   atomic.Uint64
+	requestIDPrefix string
+
+	// Fails calls to methods the peer does not serve, so that they are not sent
+	check func(method string) error
+
+	// Whether the peer supports streaming calls
+	streaming bool
+
+	// Retry policies by method name, see WithRetryPolicy
+	retryPolicies map[string]RetryPolicy
+
+	// Chained client interceptors, nil if none
+	unaryInterceptor  grpc.UnaryClientInterceptor
+	streamInterceptor grpc.StreamClientInterceptor
+
+	// Payload compression negotiated with the peer, empty if none
+	compression string
+
+	// Maps to track pending RPC calls and open streaming calls by request ID
+	requestsMu   sync.RWMutex
+	pendingCalls map[string]chan interface{}
+	streams      map[string]*clientStream
+
+	// Set once the stream is lost; calls then fail with this status error
+	closeErr error
+}
+
+var _ grpc.ClientConnInterface = (*caller)(nil)
+
+// newCaller creates a caller sending its calls with sender, configured by o.
+func newCaller(sender *sender, peer string, o *options) *caller {
+	return &caller{
+		sender:            sender,
+		peer:              peer,
+		check:             func(string) error { return nil },
+		retryPolicies:     o.retryPolicies,
+		unaryInterceptor:  o.unaryClientInterceptor(),
+		streamInterceptor: o.streamClientInterceptor(),
+		pendingCalls:      make(map[string]chan interface{}),
+		streams:           make(map[string]*clientStream),
+	}
+}
+
+// callRPC makes a unary call, retried according to the retry policy of the method.
+func (c *caller) callRPC(ctx context.Context, method string, reqPayload proto.Message, opts []grpc.CallOption) ([]byte, error) {
+	if err := c.check(method); err != nil {
+		return nil, err
+	}
+
+	// Marshal request
+	reqBytes, err := proto.Marshal(reqPayload)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal request: %v", err)
+	}
+	if err := checkPayloadSize(len(reqBytes), c.sender.maxPayloadSize); err != nil {
+		return nil, err
+	}
+
+	policy := c.retryPolicy(method)
+	key := idempotencyKeyFromOptions(opts)
+	if key == "" && policy != nil {
+		key = randomID()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.call(ctx, method, reqBytes, key, opts)
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) || c.closed() {
+			return resp, err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// call makes a single attempt of a unary call.
+func (c *caller) call(ctx context.Context, method string, reqBytes []byte, key string, opts []grpc.CallOption) ([]byte, error) {
+	requestID := c.newRequestID()
+	msg := newRPCCallMessage(ctx, requestID, method, reqBytes)
+	msg.GetRpcCall().IdempotencyKey = key
+	msg.GetRpcCall().Compression = c.compression
+
+	// Register the call before sending so that a fast response is not missed
+	respChan, err := c.registerCall(requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer c.unregisterCall(requestID)
+
+	if err := c.sender.send(ctx, msg); err != nil {
+		return nil, sendStatusError(ctx, err)
+	}
+
+	// Wait for response
+	resp, err := c.waitForResponse(ctx, requestID, respChan)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp := resp.(type) {
+	case *pluginframeworkv1.PluginRPCResponse:
+		applyCallOptions(opts, metadataFromProto(resp.GetHeader()), metadataFromProto(resp.GetTrailer()))
+		return resp.GetPayload(), nil
+	case *pluginframeworkv1.PluginError:
+		applyCallOptions(opts, metadataFromProto(resp.GetHeader()), metadataFromProto(resp.GetTrailer()))
+		return nil, errorFromProto(resp)
+	default:
+		return nil, status.Errorf(codes.Internal, "unexpected response type: %T", resp)
+	}
+}
+
+// Invoke performs a unary RPC through the stream.
+// The call goes through the client interceptors, which get a nil *grpc.ClientConn.
+func (c *caller) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	if c.unaryInterceptor == nil {
+		return c.invoke(ctx, method, args, reply, nil, opts...)
+	}
+	return c.unaryInterceptor(ctx, method, args, reply, nil, c.invoke, opts...)
+}
+
+// invoke is the grpc.UnaryInvoker at the end of the client interceptors.
+func (c *caller) invoke(ctx context.Context, method string, args, reply any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+	req, ok := args.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "request type %T is not a proto.Message", args)
+	}
+
+	respBytes, err := c.callRPC(ctx, method, req, opts)
+	if err != nil {
+		return err
+	}
+
+	switch out := reply.(type) {
+	case *rawReply:
+		out.payload = respBytes
+	case proto.Message:
+		if err := proto.Unmarshal(respBytes, out); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+		}
+	default:
+		return status.Errorf(codes.Internal, "reply type %T is not a proto.Message", reply)
+	}
+	return nil
+}
+
+// newRPCCallMessage builds the PluginRPCCall message for a call made with ctx,
+// carrying its outgoing metadata and deadline.
+func newRPCCallMessage(ctx context.Context, requestID, method string, payload []byte) *pluginframeworkv1.PluginStreamMessage {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	rpcCall := &pluginframeworkv1.PluginRPCCall{
+		RequestId: requestID,
+		Method:    method,
+		Payload:   payload,
+		Metadata:  metadataToProto(md),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		rpcCall.Timeout = durationpb.New(time.Until(deadline))
+	}
+
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcCall{
+			RpcCall: rpcCall,
+		},
+	}
+}
+
+// closeCalls marks the stream as lost: pending calls and streams complete with
+// Unavailable instead of waiting for their deadline, and new calls fail right away.
+func (c *caller) closeCalls(cause error) {
+	c.requestsMu.Lock()
+	if c.closeErr != nil {
+		c.requestsMu.Unlock()
+		return
+	}
+	c.closeErr = status.Errorf(codes.Unavailable, "%s stream closed: %v", c.peer, cause)
+
+	pending := c.pendingCalls
+	c.pendingCalls = make(map[string]chan interface{})
+	streams := make([]*clientStream, 0, len(c.streams))
+	for _, cs := range c.streams {
+		streams = append(streams, cs)
+	}
+	c.requestsMu.Unlock()
+
+	for _, respChan := range pending {
+		select {
+		case respChan <- c.closeErr:
+		default:
+			// A response was already delivered
+		}
+	}
+	for _, cs := range streams {
+		cs.finish(nil, c.closeErr)
+	}
+}
+
+// failCall completes the pending call or aborts the streaming call of requestID with err,
+// when a message of the peer for that call cannot be delivered.
+func (c *caller) failCall(requestID string, err error) {
+	c.requestsMu.RLock()
+	respChan, exists := c.pendingCalls[requestID]
+	cs := c.streams[requestID]
+	c.requestsMu.RUnlock()
+
+	switch {
+	case cs != nil:
+		c.sendCancel(requestID)
+		cs.abort(err)
+	case exists:
+		select {
+		case respChan <- err:
+		default:
+		}
+	default:
+		log.Log.Info("Dropping message for unknown request", "peer", c.peer, "requestID", requestID, "error", err.Error())
+	}
+}
+
+// closed reports whether the stream was lost.
+func (c *caller) closed() bool {
+	c.requestsMu.RLock()
+	defer c.requestsMu.RUnlock()
+
+	return c.closeErr != nil
+}
+
+// registerCall creates the channel on which the response for requestID is delivered.
+// It fails with Unavailable once the stream is lost.
+func (c *caller) registerCall(requestID string) (chan interface{}, error) {
+	respChan := make(chan interface{}, 1)
+
+	c.requestsMu.Lock()
+	defer c.requestsMu.Unlock()
+
+	if c.closeErr != nil {
+		return nil, c.closeErr
+	}
+	c.pendingCalls[requestID] = respChan
+
+	return respChan, nil
+}
+
+// unregisterCall stops tracking the pending call for requestID.
+func (c *caller) unregisterCall(requestID string) {
+	c.requestsMu.Lock()
+	delete(c.pendingCalls, requestID)
+	c.requestsMu.Unlock()
+}
+
+// waitForResponse waits for an RPC response with the given request ID.
+// If ctx is done first, the peer is told to cancel the call.
+func (c *caller) waitForResponse(ctx context.Context, requestID string, respChan chan interface{}) (interface{}, error) {
+	select {
+	case resp := <-respChan:
+		if err, ok := resp.(error); ok {
+			return nil, err
+		}
+		return resp, nil
+	case <-ctx.Done():
+		c.sendCancel(requestID)
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// sendCancel tells the peer that the caller gave up on requestID.
+// It is best effort: a failure only means the peer keeps working until its own deadline.
+// It is queued on the data lane so that it never overtakes the call it cancels.
+func (c *caller) sendCancel(requestID string) {
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_Cancel{
+			Cancel: &pluginframeworkv1.PluginCancel{
+				RequestId: requestID,
+			},
+		},
+	}
+	_ = c.sender.send(context.Background(), msg)
+}
+
+// handleResponse processes an RPC response from the peer.
+// Responses to unknown requests, for instance arriving after the caller gave up,
+// are logged and dropped.
+func (c *caller) handleResponse(rpcResp *pluginframeworkv1.PluginRPCResponse) {
+	requestID := rpcResp.GetRequestId()
+
+	c.requestsMu.RLock()
+	respChan, exists := c.pendingCalls[requestID]
+	c.requestsMu.RUnlock()
+
+	if !exists {
+		log.Log.Info("Dropping response for unknown request", "peer", c.peer, "requestID", requestID)
+		return
+	}
+
+	// Return the raw response - caller is responsible for unmarshaling
+	select {
+	case respChan <- rpcResp:
+		// Response sent to waiter
+	default:
+		// Channel full, cannot send response
+	}
+}
+
+// handleError processes an error message from the peer.
+// Errors tied to a request complete that call; other errors are only logged.
+func (c *caller) handleError(errMsg *pluginframeworkv1.PluginError) {
+	logger := log.Log.WithValues("peer", c.peer)
+
+	requestID := errMsg.GetRequestId()
+	if requestID == "" {
+		logger.Info("Peer reported an error", "code", errMsg.GetCode(), "message", errMsg.GetMessage())
+		return
+	}
+
+	c.requestsMu.RLock()
+	respChan, exists := c.pendingCalls[requestID]
+	cs := c.streams[requestID]
+	c.requestsMu.RUnlock()
+
+	if cs != nil {
+		// A streaming call failed before the handler ran
+		cs.finish(metadataFromProto(errMsg.GetTrailer()), errorFromProto(errMsg))
+		return
+	}
+
+	if !exists {
+		logger.Info("Dropping error for unknown request", "requestID", requestID, "code", errMsg.GetCode())
+		return
+	}
+
+	select {
+	case respChan <- errMsg:
+		// Error sent to waiter
+	default:
+		// Channel full, cannot send error
+	}
+}
+
+// sendStatusError converts the error of queueing a message for a call made with ctx
+// to a status error.
+func sendStatusError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	return status.Errorf(codes.Unavailable, "failed to send RPC call: %v", err)
+}
+
+// newRequestID returns a request ID that is unique on the stream.
+func (c *caller) newRequestID() string {
+	return c.requestIDPrefix + strconv.FormatUint(c.lastRequestID.Add(1), 10)
+}
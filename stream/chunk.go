@@ -0,0 +1,170 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"bytes"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+const (
+	// defaultMaxChunkSize is the default largest payload sent in a single message,
+	// well under the 4 MiB default message size limit of gRPC.
+	defaultMaxChunkSize = 1 << 20
+
+	// defaultMaxPayloadSize is the default largest payload of a call message.
+	defaultMaxPayloadSize = 64 << 20
+)
+
+// payloadRef points to the payload fields of a message.
+type payloadRef struct {
+	requestID   string
+	payload     *[]byte
+	chunks      *uint32
+	compression *string
+}
+
+// payloadFields returns the payload fields of the messages that carry a payload.
+// ok is false for the other messages.
+func payloadFields(msg *pluginframeworkv1.PluginStreamMessage) (ref payloadRef, ok bool) {
+	switch p := msg.GetPayload().(type) {
+	case *pluginframeworkv1.PluginStreamMessage_RpcCall:
+		m := p.RpcCall
+		return payloadRef{m.GetRequestId(), &m.Payload, &m.PayloadChunks, &m.Compression}, true
+	case *pluginframeworkv1.PluginStreamMessage_RpcResponse:
+		m := p.RpcResponse
+		return payloadRef{m.GetRequestId(), &m.Payload, &m.PayloadChunks, &m.Compression}, true
+	case *pluginframeworkv1.PluginStreamMessage_StreamFrame:
+		m := p.StreamFrame
+		return payloadRef{m.GetRequestId(), &m.Payload, &m.PayloadChunks, &m.Compression}, true
+	default:
+		return payloadRef{}, false
+	}
+}
+
+// splitPayload returns the messages to write for msg. A payload larger than maxChunkSize
+// is moved to PluginPayloadChunk messages, followed by msg with an empty payload.
+// msg is modified in place. A zero maxChunkSize disables splitting.
+func splitPayload(msg *pluginframeworkv1.PluginStreamMessage, maxChunkSize int) []*pluginframeworkv1.PluginStreamMessage {
+	ref, ok := payloadFields(msg)
+	if !ok || maxChunkSize <= 0 || len(*ref.payload) <= maxChunkSize {
+		return []*pluginframeworkv1.PluginStreamMessage{msg}
+	}
+
+	data := *ref.payload
+	msgs := make([]*pluginframeworkv1.PluginStreamMessage, 0, len(data)/maxChunkSize+2)
+	for index := 0; len(data) > 0; index++ {
+		n := min(maxChunkSize, len(data))
+		msgs = append(msgs, &pluginframeworkv1.PluginStreamMessage{
+			Payload: &pluginframeworkv1.PluginStreamMessage_PayloadChunk{
+				PayloadChunk: &pluginframeworkv1.PluginPayloadChunk{
+					RequestId: ref.requestID,
+					Index:     uint32(index),
+					Data:      data[:n],
+				},
+			},
+		})
+		data = data[n:]
+	}
+
+	*ref.chunks = uint32(len(msgs))
+	*ref.payload = nil
+	return append(msgs, msg)
+}
+
+// checkPayloadSize fails with RESOURCE_EXHAUSTED if a payload of size bytes is over the limit.
+func checkPayloadSize(size, maxPayloadSize int) error {
+	if size > maxPayloadSize {
+		return status.Errorf(codes.ResourceExhausted, "payload of %d bytes exceeds the limit of %d bytes", size, maxPayloadSize)
+	}
+	return nil
+}
+
+// reassembler collects the chunks received for the payloads of a stream.
+// It is only used by the receiving goroutine.
+type reassembler struct {
+	maxPayloadSize int
+	partial        map[string]*partialPayload
+}
+
+// partialPayload is a payload whose chunks are being received.
+type partialPayload struct {
+	chunks [][]byte
+	size   int
+	err    error // set when the payload is rejected, its next chunks are dropped
+}
+
+func newReassembler(maxPayloadSize int) *reassembler {
+	return &reassembler{
+		maxPayloadSize: maxPayloadSize,
+		partial:        make(map[string]*partialPayload),
+	}
+}
+
+// add records a chunk. A payload growing over the size limit is rejected.
+func (r *reassembler) add(chunk *pluginframeworkv1.PluginPayloadChunk) {
+	p := r.partial[chunk.GetRequestId()]
+	if p == nil {
+		p = &partialPayload{}
+		r.partial[chunk.GetRequestId()] = p
+	}
+	if p.err != nil {
+		return
+	}
+
+	if int(chunk.GetIndex()) != len(p.chunks) {
+		p.err = status.Errorf(codes.Internal, "payload chunk %d received out of order", chunk.GetIndex())
+		p.chunks = nil
+		return
+	}
+	p.size += len(chunk.GetData())
+	if err := checkPayloadSize(p.size, r.maxPayloadSize); err != nil {
+		p.err = err
+		p.chunks = nil
+		return
+	}
+	p.chunks = append(p.chunks, chunk.GetData())
+}
+
+// assemble restores the payload of a message sent in chunks. It returns the request ID
+// of the message and a status error if the payload was rejected or is incomplete.
+func (r *reassembler) assemble(msg *pluginframeworkv1.PluginStreamMessage) (string, error) {
+	ref, ok := payloadFields(msg)
+	if !ok || *ref.chunks == 0 {
+		return ref.requestID, nil
+	}
+
+	p := r.partial[ref.requestID]
+	delete(r.partial, ref.requestID)
+
+	switch {
+	case p == nil:
+		return ref.requestID, status.Error(codes.Internal, "payload chunks are missing")
+	case p.err != nil:
+		return ref.requestID, p.err
+	case len(p.chunks) != int(*ref.chunks):
+		return ref.requestID, status.Errorf(codes.Internal, "expected %d payload chunks, received %d", *ref.chunks, len(p.chunks))
+	}
+
+	*ref.payload = bytes.Join(p.chunks, nil)
+	*ref.chunks = 0
+	return ref.requestID, nil
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// NewStream opens a streaming RPC through the plugin stream.
+// Server-streaming, client-streaming and bidirectional methods are supported,
+// and any number of them can be open at once over the single plugin stream.
+//
+// For server-streaming methods, the single request is sent with the first SendMsg.
+// For the others, the call is opened right away; each SendMsg sends one message
+// and CloseSend half-closes the call. In both directions, a sender blocks when the
+// receiver has not read the previous messages yet (per-call flow control).
+//
+// As with a grpc.ClientConn, the caller must either cancel ctx or call RecvMsg
+// until it returns an error, otherwise the call is never released.
+// It fails with UNIMPLEMENTED when the plugin did not announce FeatureStreaming,
+// or did not advertise the method.
+// The call goes through the client interceptors, which get a nil *grpc.ClientConn.
+func (sm *StreamManager) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return sm.caller.NewStream(ctx, desc, method, opts...)
+}
+
+// NewStream opens a streaming RPC through the stream.
+// The call goes through the client interceptors, which get a nil *grpc.ClientConn.
+func (c *caller) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if c.streamInterceptor == nil {
+		return c.newStream(ctx, desc, nil, method, opts...)
+	}
+	return c.streamInterceptor(ctx, desc, nil, method, c.newStream, opts...)
+}
+
+// newStream is the grpc.Streamer at the end of the client interceptors.
+func (c *caller) newStream(ctx context.Context, desc *grpc.StreamDesc, _ *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if !c.streaming {
+		return nil, status.Errorf(codes.Unimplemented, "%s does not support streaming calls", c.peer)
+	}
+	if err := c.check(method); err != nil {
+		return nil, err
+	}
+
+	cs := &clientStream{
+		caller:        c,
+		ctx:           ctx,
+		method:        method,
+		clientStreams: desc.ClientStreams,
+		requestID:     c.newRequestID(),
+		opts:          opts,
+		recv:          newRecvQueue(),
+		window:        newSendWindow(),
+		headerCh:      make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	if cs.clientStreams {
+		if err := cs.open(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return cs, nil
+}
+
+// clientStream is the calling side of a streaming RPC call through the stream:
+// the operator's for calls to the plugin, and the plugin's for calls to the operator.
+// It implements grpc.ClientStream.
+type clientStream struct {
+	caller        *caller
+	ctx           context.Context
+	method        string
+	clientStreams bool
+	requestID     string
+	opts          []grpc.CallOption
+
+	// sent is set once the call was opened, closeSent once it was half-closed;
+	// both are only used by the sending goroutine
+	sent      bool
+	closeSent bool
+
+	recv   *recvQueue
+	window *sendWindow
+
+	mu       sync.Mutex
+	header   metadata.MD
+	trailer  metadata.MD
+	err      error
+	ended    bool
+	headerCh chan struct{} // closed when the header arrives or the call ends
+	done     chan struct{} // closed when the call ends
+}
+
+var _ grpc.ClientStream = (*clientStream)(nil)
+
+func (cs *clientStream) Header() (metadata.MD, error) {
+	if !cs.sent {
+		return nil, status.Error(codes.Internal, "Header called before SendMsg")
+	}
+
+	<-cs.headerCh
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.header == nil && cs.err != nil {
+		return nil, cs.err
+	}
+	return cs.header, nil
+}
+
+func (cs *clientStream) Trailer() metadata.MD {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.trailer
+}
+
+// CloseSend half-closes the call: the handler's RecvMsg returns io.EOF once
+// it has read the messages already sent. It is a no-op for server-streaming calls,
+// which send their only request with the call.
+func (cs *clientStream) CloseSend() error {
+	if !cs.clientStreams || cs.closeSent {
+		return nil
+	}
+	cs.closeSent = true
+
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_StreamHalfClose{
+			StreamHalfClose: &pluginframeworkv1.PluginStreamHalfClose{
+				RequestId: cs.requestID,
+			},
+		},
+	}
+	if err := cs.caller.sender.send(cs.ctx, msg); err != nil {
+		cs.finish(nil, sendStatusError(cs.ctx, err))
+	}
+	return nil
+}
+
+func (cs *clientStream) Context() context.Context {
+	return cs.ctx
+}
+
+// SendMsg sends a request message. For server-streaming calls it may only be called once.
+// As with gRPC, it returns io.EOF if the call already ended: the status is then
+// available from RecvMsg.
+func (cs *clientStream) SendMsg(m any) error {
+	if !cs.clientStreams && cs.sent {
+		return status.Errorf(codes.Internal, "SendMsg called more than once on server-streaming call %s", cs.method)
+	}
+	if cs.closeSent {
+		return status.Error(codes.Internal, "SendMsg called after CloseSend")
+	}
+
+	req, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "request type %T is not a proto.Message", m)
+	}
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal request: %v", err)
+	}
+	if err := checkPayloadSize(len(payload), cs.caller.sender.maxPayloadSize); err != nil {
+		return err
+	}
+
+	if !cs.clientStreams {
+		return cs.open(payload)
+	}
+
+	select {
+	case <-cs.done:
+		return io.EOF
+	default:
+	}
+	if !cs.window.acquire(cs.done) {
+		return io.EOF
+	}
+
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_StreamFrame{
+			StreamFrame: &pluginframeworkv1.PluginStreamFrame{
+				RequestId:   cs.requestID,
+				Payload:     payload,
+				Compression: cs.caller.compression,
+			},
+		},
+	}
+	if err := cs.caller.sender.send(cs.ctx, msg); err != nil {
+		cs.finish(nil, sendStatusError(cs.ctx, err))
+		return io.EOF
+	}
+	return nil
+}
+
+// open sends the call to the peer, with the request for server-streaming calls.
+func (cs *clientStream) open(payload []byte) error {
+	cs.sent = true
+
+	// Register the stream before sending so that fast frames are not missed
+	if err := cs.caller.registerStream(cs); err != nil {
+		cs.finish(nil, err)
+		return err
+	}
+
+	msg := newRPCCallMessage(cs.ctx, cs.requestID, cs.method, payload)
+	msg.GetRpcCall().Compression = cs.caller.compression
+	if err := cs.caller.sender.send(cs.ctx, msg); err != nil {
+		err = sendStatusError(cs.ctx, err)
+		cs.finish(nil, err)
+		return err
+	}
+
+	go cs.watchContext()
+	return nil
+}
+
+// RecvMsg receives the next message from the peer.
+// It returns io.EOF when the handler returned successfully, or its status error otherwise.
+func (cs *clientStream) RecvMsg(m any) error {
+	if !cs.sent {
+		return status.Error(codes.Internal, "RecvMsg called before SendMsg")
+	}
+
+	out, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "reply type %T is not a proto.Message", m)
+	}
+
+	payload, grant, ok := cs.recv.pop(nil)
+	if !ok {
+		cs.mu.Lock()
+		err := cs.err
+		cs.mu.Unlock()
+
+		if err == nil {
+			return io.EOF
+		}
+		return err
+	}
+
+	if grant > 0 {
+		_ = cs.caller.sender.sendControl(newWindowUpdateMessage(cs.requestID, grant))
+	}
+
+	if err := proto.Unmarshal(payload, out); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal stream message: %v", err)
+	}
+	return nil
+}
+
+// watchContext cancels the call on the peer when the caller's context is done first.
+func (cs *clientStream) watchContext() {
+	select {
+	case <-cs.ctx.Done():
+		cs.caller.sendCancel(cs.requestID)
+		cs.abort(status.FromContextError(cs.ctx.Err()).Err())
+	case <-cs.done:
+	}
+}
+
+// setHeader records the header sent by the peer.
+func (cs *clientStream) setHeader(md metadata.MD) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.ended || cs.header != nil {
+		return
+	}
+	cs.header = md
+	close(cs.headerCh)
+}
+
+// pushFrame queues a message sent by the peer.
+// A peer that overruns the flow-control window fails the call.
+func (cs *clientStream) pushFrame(payload []byte) {
+	if !cs.recv.push(payload) {
+		cs.caller.sendCancel(cs.requestID)
+		cs.abort(status.Errorf(codes.ResourceExhausted, "%s exceeded the stream flow-control window", cs.caller.peer))
+	}
+}
+
+// finish ends the call with the given trailer and final error (nil when OK).
+// Messages already received can still be read.
+func (cs *clientStream) finish(trailer metadata.MD, err error) {
+	cs.mu.Lock()
+	if cs.ended {
+		cs.mu.Unlock()
+		return
+	}
+	cs.ended = true
+	cs.trailer = trailer
+	cs.err = err
+	if cs.header == nil {
+		close(cs.headerCh)
+	}
+	header := cs.header
+	close(cs.done)
+	cs.mu.Unlock()
+
+	cs.caller.unregisterStream(cs.requestID)
+	applyCallOptions(cs.opts, header, trailer)
+	cs.recv.close()
+}
+
+// abort ends the call with err, discarding messages not read yet.
+func (cs *clientStream) abort(err error) {
+	cs.recv.discard()
+	cs.finish(nil, err)
+}
+
+// registerStream starts routing the peer's stream messages for cs.
+// It fails with Unavailable once the stream is lost.
+func (c *caller) registerStream(cs *clientStream) error {
+	c.requestsMu.Lock()
+	defer c.requestsMu.Unlock()
+
+	if c.closeErr != nil {
+		return c.closeErr
+	}
+	c.streams[cs.requestID] = cs
+	return nil
+}
+
+// unregisterStream stops routing stream messages for requestID.
+func (c *caller) unregisterStream(requestID string) {
+	c.requestsMu.Lock()
+	delete(c.streams, requestID)
+	c.requestsMu.Unlock()
+}
+
+// handleStreamMessage routes a header, frame, end-of-stream or window update message
+// to its streaming call.
+func (c *caller) handleStreamMessage(msg *pluginframeworkv1.PluginStreamMessage) {
+	var requestID string
+	switch {
+	case msg.GetStreamHeader() != nil:
+		requestID = msg.GetStreamHeader().GetRequestId()
+	case msg.GetStreamFrame() != nil:
+		requestID = msg.GetStreamFrame().GetRequestId()
+	case msg.GetStreamEnd() != nil:
+		requestID = msg.GetStreamEnd().GetRequestId()
+	case msg.GetStreamWindowUpdate() != nil:
+		requestID = msg.GetStreamWindowUpdate().GetRequestId()
+	}
+
+	c.requestsMu.RLock()
+	cs := c.streams[requestID]
+	c.requestsMu.RUnlock()
+
+	if cs == nil {
+		log.Log.Info("Dropping stream message for unknown request", "peer", c.peer, "requestID", requestID)
+		return
+	}
+
+	switch {
+	case msg.GetStreamHeader() != nil:
+		cs.setHeader(metadataFromProto(msg.GetStreamHeader().GetHeader()))
+	case msg.GetStreamFrame() != nil:
+		cs.pushFrame(msg.GetStreamFrame().GetPayload())
+	case msg.GetStreamEnd() != nil:
+		end := msg.GetStreamEnd()
+		cs.finish(metadataFromProto(end.GetTrailer()), status.ErrorProto(end.GetStatus()))
+	case msg.GetStreamWindowUpdate() != nil:
+		cs.window.add(int(msg.GetStreamWindowUpdate().GetMessages()))
+	}
+}
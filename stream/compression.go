@@ -0,0 +1,206 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// Payload compression algorithms.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// defaultCompressionThreshold is the default size under which payloads are sent uncompressed.
+const defaultCompressionThreshold = 1024
+
+// compressor compresses and decompresses payloads. Implementations are safe for concurrent use.
+type compressor interface {
+	compress(data []byte) ([]byte, error)
+	// decompress fails if the decompressed payload is larger than limit.
+	decompress(data []byte, limit int) ([]byte, error)
+}
+
+// compressors are the supported algorithms, in the order the plugin advertises them.
+var compressors = map[string]compressor{
+	CompressionZstd: &zstdCompressor{},
+	CompressionGzip: &gzipCompressor{},
+}
+
+// supportedCompression lists the algorithms a plugin accepts, in order of preference.
+var supportedCompression = []string{CompressionZstd, CompressionGzip}
+
+// negotiateCompression returns the first of the preferred algorithms the plugin accepts,
+// or an empty string if none.
+func negotiateCompression(preferred, accepted []string) string {
+	for _, algorithm := range preferred {
+		if slices.Contains(accepted, algorithm) {
+			return algorithm
+		}
+	}
+	return ""
+}
+
+// compressPayload compresses the payload of msg with the algorithm set in its compression
+// field, if the payload is at least threshold bytes and compression makes it smaller.
+// Otherwise the compression field is cleared and the payload is sent as is.
+func compressPayload(msg *pluginframeworkv1.PluginStreamMessage, threshold int) error {
+	ref, ok := payloadFields(msg)
+	if !ok || *ref.compression == "" {
+		return nil
+	}
+
+	algorithm := *ref.compression
+	c, known := compressors[algorithm]
+	if !known || len(*ref.payload) == 0 || len(*ref.payload) < threshold {
+		*ref.compression = ""
+		return nil
+	}
+
+	compressed, err := c.compress(*ref.payload)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to compress payload with %s: %v", algorithm, err)
+	}
+	observeCompression(algorithm, len(*ref.payload), len(compressed))
+
+	if len(compressed) >= len(*ref.payload) {
+		*ref.compression = ""
+		return nil
+	}
+	*ref.payload = compressed
+	return nil
+}
+
+// decompressPayload decompresses the payload of msg in place, and returns the algorithm
+// it was compressed with.
+func decompressPayload(msg *pluginframeworkv1.PluginStreamMessage, limit int) (string, error) {
+	ref, ok := payloadFields(msg)
+	if !ok || *ref.compression == "" {
+		return "", nil
+	}
+
+	algorithm := *ref.compression
+	c, known := compressors[algorithm]
+	if !known {
+		return "", status.Errorf(codes.Unimplemented, "unsupported payload compression %q", algorithm)
+	}
+
+	payload, err := c.decompress(*ref.payload, limit)
+	if err != nil {
+		return "", err
+	}
+	*ref.payload = payload
+	*ref.compression = ""
+	return algorithm, nil
+}
+
+// readLimited reads r until EOF, failing with RESOURCE_EXHAUSTED past limit bytes.
+func readLimited(r io.Reader, limit int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decompress payload: %v", err)
+	}
+	if err := checkPayloadSize(len(data), limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type gzipCompressor struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func (g *gzipCompressor) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, ok := g.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(&buf)
+	} else {
+		w = gzip.NewWriter(&buf)
+	}
+	defer g.writers.Put(w)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *gzipCompressor) decompress(data []byte, limit int) ([]byte, error) {
+	var (
+		r   *gzip.Reader
+		err error
+	)
+	if pooled, ok := g.readers.Get().(*gzip.Reader); ok {
+		r = pooled
+		err = r.Reset(bytes.NewReader(data))
+	} else {
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decompress payload: %v", err)
+	}
+	defer g.readers.Put(r)
+
+	return readLimited(r, limit)
+}
+
+type zstdCompressor struct {
+	encoderOnce sync.Once
+	encoder     *zstd.Encoder
+	decoders    sync.Pool
+}
+
+func (z *zstdCompressor) compress(data []byte) ([]byte, error) {
+	z.encoderOnce.Do(func() {
+		// EncodeAll is safe for concurrent use; creating an encoder without options cannot fail
+		z.encoder, _ = zstd.NewWriter(nil)
+	})
+	return z.encoder.EncodeAll(data, nil), nil
+}
+
+func (z *zstdCompressor) decompress(data []byte, limit int) ([]byte, error) {
+	d, ok := z.decoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		d, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to create zstd decoder: %v", err)
+		}
+	}
+	defer z.decoders.Put(d)
+
+	if err := d.Reset(bytes.NewReader(data)); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decompress payload: %v", err)
+	}
+	return readLimited(d, limit)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// dedupCache remembers the responses of unary calls by idempotency key, so that
+// a retried call does not run its handler twice.
+type dedupCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	order   *list.List // keys of completed entries, oldest first
+}
+
+// dedupEntry is a call being handled or a stored response.
+type dedupEntry struct {
+	resp    *pluginframeworkv1.PluginRPCResponse // nil while the call runs
+	done    chan struct{}                        // closed when the call completes or fails
+	expires time.Time
+	elem    *list.Element
+}
+
+// newDedupCache returns the cache configured by o, or nil if it is disabled.
+func newDedupCache(o *options) *dedupCache {
+	if o.idempotencyTTL <= 0 {
+		return nil
+	}
+	return &dedupCache{
+		ttl:        o.idempotencyTTL,
+		maxEntries: o.idempotencyMaxEntries,
+		entries:    make(map[string]*dedupEntry),
+		order:      list.New(),
+	}
+}
+
+// begin looks up key. It returns the stored response if there is one. Otherwise,
+// leader is true when the caller must run the handler and then call complete or
+// release; when false, another call with the same key is running and wait is closed
+// once it is done, after which begin should be called again.
+func (c *dedupCache) begin(key string) (resp *pluginframeworkv1.PluginRPCResponse, wait <-chan struct{}, leader bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLocked(time.Now())
+
+	if entry, ok := c.entries[key]; ok {
+		if entry.resp != nil {
+			return entry.resp, nil, false
+		}
+		return nil, entry.done, false
+	}
+
+	c.entries[key] = &dedupEntry{done: make(chan struct{})}
+	return nil, nil, true
+}
+
+// complete stores the response of the call running for key and wakes up its duplicates.
+func (c *dedupCache) complete(key string, resp *pluginframeworkv1.PluginRPCResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.resp != nil {
+		return
+	}
+	entry.resp = resp
+	entry.expires = time.Now().Add(c.ttl)
+	entry.elem = c.order.PushBack(key)
+	close(entry.done)
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.removeLocked(c.order.Front().Value.(string))
+	}
+}
+
+// release forgets the call running for key if it did not complete, so that one of
+// its duplicates runs the handler instead.
+func (c *dedupCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok && entry.resp == nil {
+		delete(c.entries, key)
+		close(entry.done)
+	}
+}
+
+// expireLocked removes the stored responses older than the TTL.
+func (c *dedupCache) expireLocked(now time.Time) {
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		key := front.Value.(string)
+		if c.entries[key].expires.After(now) {
+			return
+		}
+		c.removeLocked(key)
+	}
+}
+
+func (c *dedupCache) removeLocked(key string) {
+	entry := c.entries[key]
+	c.order.Remove(entry.elem)
+	delete(c.entries, key)
+}
+
+// dedupKey is the cache key of a call: keys are only unique per method.
+func dedupKey(rpcCall *pluginframeworkv1.PluginRPCCall) string {
+	return rpcCall.GetMethod() + "\x00" + rpcCall.GetIdempotencyKey()
+}
+
+// replayResponse answers a duplicate call with the stored response of the first one.
+func replayResponse(requestID string, resp *pluginframeworkv1.PluginRPCResponse, compression string) *pluginframeworkv1.PluginStreamMessage {
+	replay := proto.Clone(resp).(*pluginframeworkv1.PluginRPCResponse)
+	replay.RequestId = requestID
+	replay.Compression = compression
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
+			RpcResponse: replay,
+		},
+	}
+}
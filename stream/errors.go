@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// ErrSlowConsumer is returned by the receive loops when the peer stopped reading the
+// stream: a message could not be written within the slow-consumer timeout.
+var ErrSlowConsumer = errors.New("peer stopped reading the plugin stream")
+
+// errStreamReplaced ends ListenForMessages on a stream replaced by the plugin resuming its session.
+var errStreamReplaced = errors.New("plugin resumed its session on another stream")
+
+// errConnectionLost fails the messages sent on a plugin stream abandoned for a new one.
+var errConnectionLost = status.Error(codes.Unavailable, "connection to the operator lost")
+
+// IsPluginUnavailable reports whether err means the plugin could not be reached,
+// for example because it is not connected or its stream was lost.
+// Callers typically requeue and try again later.
+func IsPluginUnavailable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// IsMethodUnimplemented reports whether err means the plugin does not implement the called method.
+func IsMethodUnimplemented(err error) bool {
+	return status.Code(err) == codes.Unimplemented
+}
+
+// IsPluginOverloaded reports whether err means the plugin rejected the call because
+// it was already running as many calls as it accepts.
+func IsPluginOverloaded(err error) bool {
+	return status.Code(err) == codes.ResourceExhausted
+}
+
+// IsRetryable reports whether the call that failed with err may be retried:
+// the plugin was unavailable, or it rejected the call with a retry delay.
+func IsRetryable(err error) bool {
+	if IsPluginUnavailable(err) {
+		return true
+	}
+	_, ok := RetryDelay(err)
+	return ok
+}
+
+// RetryDelay returns the delay after which the plugin suggests retrying the call
+// that failed with err, if the error carries one.
+// Reconcilers can use it as RequeueAfter.
+func RetryDelay(err error) (time.Duration, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// IsDeadlineExceeded reports whether err means the call did not complete before its deadline.
+func IsDeadlineExceeded(err error) bool {
+	return status.Code(err) == codes.DeadlineExceeded
+}
+
+// handlerStatus converts an error returned by a plugin handler to a status,
+// the same way a gRPC server would.
+func handlerStatus(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err)
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// errorFromProto converts a PluginError to a status error.
+// Errors sent by older plugins carry no status and are reported with code Unknown.
+// A status with code OK is not a valid error and is reported the same way.
+func errorFromProto(errMsg *pluginframeworkv1.PluginError) error {
+	if err := status.ErrorProto(errMsg.GetStatus()); err != nil {
+		return err
+	}
+	return status.Errorf(codes.Unknown, "plugin error %s: %s", errMsg.GetCode(), errMsg.GetMessage())
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"sync"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// streamWindow is the initial flow-control window of a streaming call, in messages.
+// Each side may send that many stream messages before the receiver grants more
+// with a PluginStreamWindowUpdate, so a slow reader bounds the memory used by a call.
+const streamWindow = 64
+
+// sendWindow tracks how many stream messages may still be sent on a streaming call.
+type sendWindow struct {
+	mu      sync.Mutex
+	credits int
+	notify  chan struct{}
+}
+
+func newSendWindow() *sendWindow {
+	return &sendWindow{
+		credits: streamWindow,
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// acquire takes one credit, blocking until one is available.
+// It returns false if done is closed first.
+func (w *sendWindow) acquire(done <-chan struct{}) bool {
+	for {
+		w.mu.Lock()
+		if w.credits > 0 {
+			w.credits--
+			w.mu.Unlock()
+			return true
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.notify:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// add grants n more credits.
+func (w *sendWindow) add(n int) {
+	w.mu.Lock()
+	w.credits += n
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// recvQueue buffers the stream messages received on a streaming call until they are read.
+// It holds at most streamWindow messages: the sender must wait for credits.
+type recvQueue struct {
+	mu       sync.Mutex
+	frames   [][]byte
+	closed   bool
+	consumed int
+	notify   chan struct{}
+}
+
+func newRecvQueue() *recvQueue {
+	return &recvQueue{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// push queues a message. It returns false if the sender overran its window.
+// Messages pushed after close are ignored.
+func (q *recvQueue) push(payload []byte) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return true
+	}
+	if len(q.frames) >= streamWindow {
+		q.mu.Unlock()
+		return false
+	}
+	q.frames = append(q.frames, payload)
+	q.mu.Unlock()
+
+	q.signal()
+	return true
+}
+
+// close marks the end of the messages: pop returns the queued ones, then reports the end.
+func (q *recvQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// discard closes the queue and drops the messages not read yet.
+func (q *recvQueue) discard() {
+	q.mu.Lock()
+	q.closed = true
+	q.frames = nil
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// pop returns the next message, blocking until one arrives or the queue is closed.
+// ok is false once the queue is closed and drained, or when done is closed.
+// grant is the number of credits to give back to the sender, zero if none are due yet.
+func (q *recvQueue) pop(done <-chan struct{}) (payload []byte, grant int, ok bool) {
+	for {
+		q.mu.Lock()
+		if len(q.frames) > 0 {
+			payload = q.frames[0]
+			q.frames = q.frames[1:]
+
+			// Grant credits back in batches of half a window
+			q.consumed++
+			if q.consumed >= streamWindow/2 {
+				grant = q.consumed
+				q.consumed = 0
+			}
+			q.mu.Unlock()
+			return payload, grant, true
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return nil, 0, false
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-done:
+			return nil, 0, false
+		}
+	}
+}
+
+func (q *recvQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// newWindowUpdateMessage builds the message granting n more stream messages on requestID.
+func newWindowUpdateMessage(requestID string, n int) *pluginframeworkv1.PluginStreamMessage {
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_StreamWindowUpdate{
+			StreamWindowUpdate: &pluginframeworkv1.PluginStreamWindowUpdate{
+				RequestId: requestID,
+				Messages:  uint32(n),
+			},
+		},
+	}
+}
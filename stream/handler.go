@@ -0,0 +1,318 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// dispatcher runs the handlers of the services served on a stream: those of the plugin,
+// called by the operator, and the callback services of the operator, called by the plugin.
+type dispatcher struct {
+	// Services served, routed by full method name
+	services *serviceSet
+
+	// Chained interceptors of the handlers, recovering from panics first
+	unaryInterceptor  grpc.UnaryServerInterceptor
+	streamInterceptor grpc.StreamServerInterceptor
+
+	// Bounds the number of handlers running at once
+	limiter *callLimiter
+
+	// Responses of unary calls by idempotency key, nil if disabled.
+	// It outlives connections, so that calls retried after a reconnect are answered.
+	dedup *dedupCache
+}
+
+// newDispatcher creates a dispatcher serving no service yet, configured by o.
+func newDispatcher(o *options) *dispatcher {
+	return &dispatcher{
+		services:          newServiceSet(),
+		unaryInterceptor:  o.unaryServerInterceptor(),
+		streamInterceptor: o.streamServerInterceptor(),
+		limiter:           newCallLimiter(o),
+		dedup:             newDedupCache(o),
+	}
+}
+
+// serveCall runs the handler of rpcCall, received on conn, on its own goroutine.
+// It must be called on the receiving goroutine, see handlerConn.startCall.
+func (d *dispatcher) serveCall(ctx context.Context, conn *handlerConn, rpcCall *pluginframeworkv1.PluginRPCCall, compression string) {
+	callCtx, call := conn.startCall(ctx, rpcCall, compression, d.isUnary(rpcCall.GetMethod()))
+	go d.runCall(callCtx, rpcCall, call)
+}
+
+// runCall handles an RPC call on its own goroutine.
+func (d *dispatcher) runCall(ctx context.Context, rpcCall *pluginframeworkv1.PluginRPCCall, call *inflightCall) {
+	defer call.conn.finishCall(rpcCall.GetRequestId(), call)
+
+	// Answer retried calls from the idempotency cache
+	if d.dedup != nil && rpcCall.GetIdempotencyKey() != "" && call.unary {
+		call.dedupKey = dedupKey(rpcCall)
+		for {
+			resp, wait, leader := d.dedup.begin(call.dedupKey)
+			if resp != nil {
+				if err := call.conn.sender.send(context.Background(), replayResponse(rpcCall.GetRequestId(), resp, call.compression)); err != nil {
+					log.FromContext(ctx).Error(err, "Failed to replay RPC response", "method", rpcCall.GetMethod(), "requestID", rpcCall.GetRequestId())
+				}
+				return
+			}
+			if leader {
+				defer d.dedup.release(call.dedupKey)
+				break
+			}
+
+			select {
+			case <-wait:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	// Wait for a handler slot, or reject the call when saturated
+	release, err := d.limiter.acquire(ctx, rpcCall.GetMethod())
+	if err != nil {
+		if err := call.conn.sendError(rpcCall.GetRequestId(), status.Convert(err), &serverTransportStream{}); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to reject RPC call", "method", rpcCall.GetMethod(), "requestID", rpcCall.GetRequestId())
+		}
+		return
+	}
+	defer release()
+
+	if err := d.handleRPCCall(ctx, rpcCall, call); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to answer RPC call", "method", rpcCall.GetMethod(), "requestID", rpcCall.GetRequestId())
+	}
+}
+
+// isUnary reports whether fullMethod is a unary method of a served service.
+func (d *dispatcher) isUnary(fullMethod string) bool {
+	info, method := d.services.lookup(fullMethod)
+	return info != nil && info.methods[method] != nil
+}
+
+// handleRPCCall processes a single RPC call from the peer.
+func (d *dispatcher) handleRPCCall(ctx context.Context, rpcCall *pluginframeworkv1.PluginRPCCall, call *inflightCall) error {
+	requestID := rpcCall.GetRequestId()
+	fullMethod := rpcCall.GetMethod()
+
+	// Expose the caller's metadata and collect the handler's header and trailer
+	ctx = metadata.NewIncomingContext(ctx, metadataFromProto(rpcCall.GetMetadata()))
+	sts := &serverTransportStream{method: fullMethod}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, sts)
+
+	info, method := d.services.lookup(fullMethod)
+	if info == nil {
+		return call.conn.sendError(requestID, status.Newf(codes.Unimplemented, "unknown method %s", fullMethod), sts)
+	}
+	if sd := info.streams[method]; sd != nil {
+		return d.handleStreamCall(ctx, rpcCall, call, info.impl, sd, sts)
+	}
+
+	dec := func(v interface{}) error {
+		return proto.Unmarshal(rpcCall.GetPayload(), v.(proto.Message))
+	}
+	out, err := info.methods[method].Handler(info.impl, ctx, dec, d.unaryInterceptor)
+	if err != nil {
+		return call.conn.sendError(requestID, handlerStatus(err), sts)
+	}
+	reply, ok := out.(proto.Message)
+	if !ok || !reply.ProtoReflect().IsValid() {
+		return call.conn.sendError(requestID, status.Newf(codes.Internal, "handler of %s returned no response message", fullMethod), sts)
+	}
+	respBytes, err := proto.Marshal(reply)
+	if err != nil {
+		return call.conn.sendError(requestID, status.Newf(codes.Internal, "failed to marshal response: %v", err), sts)
+	}
+	if err := checkPayloadSize(len(respBytes), call.conn.sender.maxPayloadSize); err != nil {
+		return call.conn.sendError(requestID, status.Convert(err), sts)
+	}
+	header, trailer := sts.collected()
+	resp := &pluginframeworkv1.PluginRPCResponse{
+		RequestId:   requestID,
+		Payload:     respBytes,
+		Header:      metadataToProto(header),
+		Trailer:     metadataToProto(trailer),
+		Compression: call.compression,
+	}
+	if call.dedupKey != "" {
+		d.dedup.complete(call.dedupKey, proto.Clone(resp).(*pluginframeworkv1.PluginRPCResponse))
+	}
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
+			RpcResponse: resp,
+		},
+	}
+	return call.conn.sender.send(context.Background(), msg)
+}
+
+// handlerConn is the side of a stream handling the calls of the peer: it tracks the calls
+// in flight, so that the peer can cancel them and send them stream messages, and sends
+// their answers.
+type handlerConn struct {
+	// Single writer of the stream
+	sender *sender
+
+	// In-flight calls by request ID. Request IDs are only unique within a stream.
+	inflightMu sync.Mutex
+	inflight   map[string]*inflightCall
+}
+
+func newHandlerConn(sender *sender) *handlerConn {
+	return &handlerConn{
+		sender:   sender,
+		inflight: make(map[string]*inflightCall),
+	}
+}
+
+// handleCallMessage delivers a cancellation, a stream message or a half-close sent by the
+// peer to the call in flight it is for.
+func (conn *handlerConn) handleCallMessage(msg *pluginframeworkv1.PluginStreamMessage) {
+	switch {
+	case msg.GetCancel() != nil:
+		conn.cancelCall(msg.GetCancel().GetRequestId())
+	case msg.GetStreamFrame() != nil:
+		frame := msg.GetStreamFrame()
+		if call := conn.lookupCall(frame.GetRequestId()); call != nil && !call.recv.push(frame.GetPayload()) {
+			// The peer overran the flow-control window
+			call.cancel()
+		}
+	case msg.GetStreamHalfClose() != nil:
+		if call := conn.lookupCall(msg.GetStreamHalfClose().GetRequestId()); call != nil {
+			call.recv.close()
+		}
+	case msg.GetStreamWindowUpdate() != nil:
+		update := msg.GetStreamWindowUpdate()
+		if call := conn.lookupCall(update.GetRequestId()); call != nil {
+			call.window.add(int(update.GetMessages()))
+		}
+	}
+}
+
+// startCall derives the handler context of an RPC call, applying the caller's
+// deadline, and tracks it so that the caller can cancel it.
+// It runs on the receiving goroutine, so that stream messages following the call
+// always find it.
+func (conn *handlerConn) startCall(ctx context.Context, rpcCall *pluginframeworkv1.PluginRPCCall, compression string, unary bool) (context.Context, *inflightCall) {
+	var (
+		callCtx context.Context
+		cancel  context.CancelFunc
+	)
+	if timeout := rpcCall.GetTimeout(); timeout != nil {
+		callCtx, cancel = context.WithTimeout(ctx, timeout.AsDuration())
+	} else {
+		callCtx, cancel = context.WithCancel(ctx)
+	}
+
+	call := &inflightCall{
+		conn:        conn,
+		cancel:      cancel,
+		recv:        newRecvQueue(),
+		window:      newSendWindow(),
+		unary:       unary,
+		compression: compression,
+	}
+
+	conn.inflightMu.Lock()
+	conn.inflight[rpcCall.GetRequestId()] = call
+	conn.inflightMu.Unlock()
+
+	return callCtx, call
+}
+
+// finishCall stops tracking an RPC call and releases its context.
+func (conn *handlerConn) finishCall(requestID string, call *inflightCall) {
+	conn.inflightMu.Lock()
+	delete(conn.inflight, requestID)
+	conn.inflightMu.Unlock()
+
+	call.cancel()
+}
+
+// lookupCall returns the in-flight call for requestID, or nil.
+// Unknown request IDs are expected: the call may already have completed.
+func (conn *handlerConn) lookupCall(requestID string) *inflightCall {
+	conn.inflightMu.Lock()
+	defer conn.inflightMu.Unlock()
+
+	return conn.inflight[requestID]
+}
+
+// cancelCall cancels the handler context of an in-flight RPC call.
+func (conn *handlerConn) cancelCall(requestID string) {
+	if call := conn.lookupCall(requestID); call != nil {
+		call.cancel()
+	}
+}
+
+// rejectMessage fails the call of a message whose payload could not be received:
+// a call is answered with the error without running its handler, and a streaming call
+// is cancelled.
+func (conn *handlerConn) rejectMessage(msg *pluginframeworkv1.PluginStreamMessage, requestID string, err error) {
+	if msg.GetRpcCall() != nil {
+		if err := conn.sendError(requestID, status.Convert(err), &serverTransportStream{}); err != nil {
+			log.Log.Error(err, "Failed to reject RPC call", "method", msg.GetRpcCall().GetMethod(), "requestID", requestID)
+		}
+		return
+	}
+	conn.cancelCall(requestID)
+}
+
+// sendError completes an RPC call with an error status and the metadata set by its handler.
+func (conn *handlerConn) sendError(requestID string, st *status.Status, sts *serverTransportStream) error {
+	header, trailer := sts.collected()
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_Error{
+			Error: &pluginframeworkv1.PluginError{
+				RequestId: requestID,
+				Code:      st.Code().String(),
+				Message:   st.Message(),
+				Status:    st.Proto(),
+				Header:    metadataToProto(header),
+				Trailer:   metadataToProto(trailer),
+			},
+		},
+	}
+	return conn.sender.sendControl(msg)
+}
+
+// inflightCall is the state of an RPC call being handled.
+type inflightCall struct {
+	conn   *handlerConn // stream the call came from, and its answer goes to
+	cancel context.CancelFunc
+	recv   *recvQueue  // stream messages sent by the caller
+	window *sendWindow // credits for stream messages sent to the caller
+
+	// unary is set for calls of unary methods, which may finish after their stream is lost
+	unary bool
+
+	// dedupKey is set when the response must be stored in the idempotency cache
+	dedupKey string
+
+	// compression is the algorithm of the call, used for the payloads sent back
+	compression string
+}
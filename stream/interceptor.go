@@ -0,0 +1,165 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"runtime/debug"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RecoveryHandler turns a panic recovered in a plugin handler into the error returned
+// to the operator. fullMethod is the method of the call and p the recovered value.
+type RecoveryHandler func(ctx context.Context, fullMethod string, p any) error
+
+// defaultRecoveryHandler logs the panic with its stack trace and fails the call with INTERNAL.
+func defaultRecoveryHandler(ctx context.Context, fullMethod string, p any) error {
+	log.FromContext(ctx).Error(nil, "Recovered from panic in plugin handler",
+		"method", fullMethod, "panic", p, "stack", string(debug.Stack()))
+	return status.Errorf(codes.Internal, "panic in handler of %s: %v", fullMethod, p)
+}
+
+// recoveryUnaryInterceptor converts panics of the next handlers into errors.
+func recoveryUnaryInterceptor(recovery RecoveryHandler) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				resp, err = nil, recovery(ctx, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// recoveryStreamInterceptor converts panics of the next handlers into errors.
+func recoveryStreamInterceptor(recovery RecoveryHandler) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovery(ss.Context(), info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// unaryServerInterceptor returns the plugin-side unary interceptors, chained after
+// the recovery from panics.
+func (o *options) unaryServerInterceptor() grpc.UnaryServerInterceptor {
+	interceptors := append([]grpc.UnaryServerInterceptor{recoveryUnaryInterceptor(o.recoveryHandler)}, o.unaryInterceptors...)
+	return chainUnaryInterceptors(interceptors)
+}
+
+// streamServerInterceptor returns the plugin-side stream interceptors, chained after
+// the recovery from panics.
+func (o *options) streamServerInterceptor() grpc.StreamServerInterceptor {
+	interceptors := append([]grpc.StreamServerInterceptor{recoveryStreamInterceptor(o.recoveryHandler)}, o.streamInterceptors...)
+	return chainStreamInterceptors(interceptors)
+}
+
+// chainUnaryInterceptors combines interceptors into one, the first being the outermost.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return chainedUnaryHandler(interceptors, 0, info, handler)(ctx, req)
+	}
+}
+
+func chainedUnaryHandler(interceptors []grpc.UnaryServerInterceptor, curr int, info *grpc.UnaryServerInfo, final grpc.UnaryHandler) grpc.UnaryHandler {
+	if curr == len(interceptors) {
+		return final
+	}
+	return func(ctx context.Context, req any) (any, error) {
+		return interceptors[curr](ctx, req, info, chainedUnaryHandler(interceptors, curr+1, info, final))
+	}
+}
+
+// chainStreamInterceptors combines interceptors into one, the first being the outermost.
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return chainedStreamHandler(interceptors, 0, info, handler)(srv, ss)
+	}
+}
+
+func chainedStreamHandler(interceptors []grpc.StreamServerInterceptor, curr int, info *grpc.StreamServerInfo, final grpc.StreamHandler) grpc.StreamHandler {
+	if curr == len(interceptors) {
+		return final
+	}
+	return func(srv any, ss grpc.ServerStream) error {
+		return interceptors[curr](srv, ss, info, chainedStreamHandler(interceptors, curr+1, info, final))
+	}
+}
+
+// DialOptions returns the options applying the client interceptors and retry policies of
+// opts to a grpc.ClientConn, for the plugins dialed by the operator rather than connecting
+// to it. Idempotency keys are not sent on such connections.
+func DialOptions(opts ...Option) []grpc.DialOption {
+	o := newOptions(opts)
+	unary := slices.Clone(o.unaryClientInterceptors)
+	if len(o.retryPolicies) > 0 {
+		// The attempts run inside the interceptors, as for calls through a plugin stream
+		unary = append(unary, retryUnaryInterceptor(o.retryPolicies))
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(o.streamClientInterceptors...),
+	}
+}
+
+// unaryClientInterceptor returns the operator-side unary interceptors chained, or nil if none.
+func (o *options) unaryClientInterceptor() grpc.UnaryClientInterceptor {
+	if len(o.unaryClientInterceptors) == 0 {
+		return nil
+	}
+	interceptors := o.unaryClientInterceptors
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return chainedUnaryInvoker(interceptors, 0, invoker)(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func chainedUnaryInvoker(interceptors []grpc.UnaryClientInterceptor, curr int, final grpc.UnaryInvoker) grpc.UnaryInvoker {
+	if curr == len(interceptors) {
+		return final
+	}
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return interceptors[curr](ctx, method, req, reply, cc, chainedUnaryInvoker(interceptors, curr+1, final), opts...)
+	}
+}
+
+// streamClientInterceptor returns the operator-side stream interceptors chained, or nil if none.
+func (o *options) streamClientInterceptor() grpc.StreamClientInterceptor {
+	if len(o.streamClientInterceptors) == 0 {
+		return nil
+	}
+	interceptors := o.streamClientInterceptors
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return chainedStreamer(interceptors, 0, streamer)(ctx, desc, cc, method, opts...)
+	}
+}
+
+func chainedStreamer(interceptors []grpc.StreamClientInterceptor, curr int, final grpc.Streamer) grpc.Streamer {
+	if curr == len(interceptors) {
+		return final
+	}
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return interceptors[curr](ctx, desc, cc, method, chainedStreamer(interceptors, curr+1, final), opts...)
+	}
+}
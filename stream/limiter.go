@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// defaultMaxQueuedCalls is the default number of calls waiting for a handler slot.
+	defaultMaxQueuedCalls = 100
+
+	// overloadRetryDelay is the retry delay suggested to the operator when a call is rejected.
+	overloadRetryDelay = time.Second
+)
+
+// callLimiter bounds the number of RPC handlers running at once on the plugin side,
+// globally and per method. Calls beyond the limits wait in a bounded queue;
+// calls beyond the queue are rejected with RESOURCE_EXHAUSTED.
+type callLimiter struct {
+	maxConcurrent int            // 0 means unlimited
+	maxQueued     int            // calls allowed to wait for a slot
+	methodLimits  map[string]int // per-method limits, by full or short method name
+
+	mu            sync.Mutex
+	running       int
+	waiting       int
+	methodRunning map[string]int
+	released      chan struct{} // closed and replaced whenever a slot is released
+}
+
+func newCallLimiter(o *options) *callLimiter {
+	return &callLimiter{
+		maxConcurrent: o.maxConcurrentCalls,
+		maxQueued:     o.maxQueuedCalls,
+		methodLimits:  o.methodLimits,
+		methodRunning: make(map[string]int),
+		released:      make(chan struct{}),
+	}
+}
+
+// acquire waits for a handler slot for method. The returned function releases it.
+// It fails with RESOURCE_EXHAUSTED when the queue is full, or with the context
+// status if ctx is done while waiting.
+func (l *callLimiter) acquire(ctx context.Context, method string) (func(), error) {
+	l.mu.Lock()
+	if l.tryAcquireLocked(method) {
+		l.mu.Unlock()
+		return func() { l.release(method) }, nil
+	}
+	if l.waiting >= l.maxQueued {
+		l.mu.Unlock()
+		return nil, overloadedError(method)
+	}
+
+	l.waiting++
+	for {
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.waiting--
+			l.mu.Unlock()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		l.mu.Lock()
+		if l.tryAcquireLocked(method) {
+			l.waiting--
+			l.mu.Unlock()
+			return func() { l.release(method) }, nil
+		}
+	}
+}
+
+// tryAcquireLocked takes a slot if both the global and the method limits allow it.
+func (l *callLimiter) tryAcquireLocked(method string) bool {
+	if l.maxConcurrent > 0 && l.running >= l.maxConcurrent {
+		return false
+	}
+	if limit, ok := l.methodLimit(method); ok && l.methodRunning[method] >= limit {
+		return false
+	}
+
+	l.running++
+	l.methodRunning[method]++
+	return true
+}
+
+// release frees the slot of method and wakes up the waiting calls.
+func (l *callLimiter) release(method string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.running--
+	l.methodRunning[method]--
+	if l.methodRunning[method] == 0 {
+		delete(l.methodRunning, method)
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// methodLimit returns the limit configured for method, by full name first, then by short name.
+func (l *callLimiter) methodLimit(method string) (int, bool) {
+	if limit, ok := l.methodLimits[method]; ok {
+		return limit, true
+	}
+	limit, ok := l.methodLimits[path.Base(method)]
+	return limit, ok
+}
+
+// overloadedError is the retryable error returned when the plugin is saturated.
+// It carries a RetryInfo detail so that the operator knows when to try again.
+func overloadedError(method string) error {
+	st := status.Newf(codes.ResourceExhausted, "plugin is overloaded, rejecting call to %s", method)
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(overloadRetryDelay)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
@@ -18,27 +18,76 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
 )
 
 // StreamManager manages bidirectional gRPC streams with plugins.
-// It handles plugin registration, RPC call forwarding, and response correlation.
+// It handles plugin registration, RPC call forwarding, and response correlation,
+// and serves the callback services of the operator to the plugin (see WithCallbackService).
 type StreamManager struct {
-	stream     StreamInterface
 	pluginName string
 	pluginVer  string
 
-	// Map to track pending RPC calls by request ID
-	requestsMu   sync.RWMutex
-	pendingCalls map[string]chan interface{}
+	// Stream serving the session, replaced when the plugin resumes it on a new one
+	attachMu sync.Mutex
+	attached *attachedStream
+
+	// Calls to the plugin, sent through the single writer of the stream
+	*caller
+
+	// Calls of the plugin to the callback services, and the context of their handlers,
+	// cancelled when the stream is closed
+	callbacks     *dispatcher
+	served        *handlerConn
+	callbackCtx   context.Context
+	stopCallbacks context.CancelFunc
+
+	// Handler of the notifications of the plugin, nil if they are not accepted
+	notificationHandler NotificationHandler
+
+	// Payload chunks being received, kept across the streams of a session
+	chunks *reassembler
+
+	// Time the last message was received from the plugin, in Unix nanoseconds
+	lastReceived atomic.Int64
+
+	// Session negotiated at registration
+	sessionID string
+	features  []string
+
+	// Resumable session, nil if the plugin or the operator does not support it.
+	// The expiry timer runs while the session has no stream.
+	session     *sessionLog
+	sessions    *SessionStore
+	resumeToken string
+	resumeMu    sync.Mutex
+	expiry      *time.Timer
+
+	// Methods advertised by the plugin, none for legacy plugins
+	methodsMu       sync.RWMutex
+	methods         []string
+	fileDescriptors []*descriptorpb.FileDescriptorProto
 }
 
+var _ grpc.ClientConnInterface = (*StreamManager)(nil)
+
 // StreamInterface defines the minimal interface required for bidirectional streaming.
 type StreamInterface interface {
 	Send(*pluginframeworkv1.PluginStreamMessage) error
@@ -47,9 +96,17 @@ type StreamInterface interface {
 }
 
 // NewStreamManager creates a new StreamManager from a bidirectional stream.
-// It expects the first message to be a PluginRegister message.
+// It expects the first message to be a PluginRegister message, and answers plugins
+// announcing a protocol version with a PluginRegisterAck.
+// A plugin refused by the WithAdmission function gets the reason in the acknowledgement,
+// and NewStreamManager returns the error of the function.
+// ListenForMessages must then run for the lifetime of the stream.
+//
+// With WithSessionStore, a plugin resuming its session gets the StreamManager of the
+// session back, now serving stream.
 func NewStreamManager(
 	stream StreamInterface,
+	opts ...Option,
 ) (*StreamManager, error) {
 	// Wait for the first message (plugin registration)
 	msg, err := stream.Recv()
@@ -61,17 +118,118 @@ func NewStreamManager(
 	if register == nil {
 		return nil, fmt.Errorf("first message must be PluginRegister")
 	}
+	// Legacy plugins do not wait for an acknowledgement
+	acknowledge := register.GetProtocolVersion() > 0
+
+	o := newOptions(opts)
+	callbacks, err := o.callbackDispatcher()
+	if err != nil {
+		return nil, err
+	}
+	fileDescriptors, err := parseFileDescriptors(register.GetFileDescriptors())
+	if err == nil && o.admission != nil {
+		err = o.admission(register)
+	}
+	if err != nil {
+		if acknowledge {
+			ack := &pluginframeworkv1.PluginRegisterAck{Rejection: status.Convert(err).Proto()}
+			if sendErr := stream.Send(newRegisterAckMessage(ack)); sendErr != nil {
+				log.Log.Error(sendErr, "Failed to send registration rejection", "plugin", register.Name)
+			}
+		}
+		return nil, err
+	}
 
+	// Resume the session of the plugin, or start a new one when it cannot be resumed
+	if token := register.GetResumeToken(); token != "" && o.sessions != nil {
+		if sm := o.sessions.get(token); sm != nil && sm.pluginName == register.Name {
+			err := sm.resume(stream, register, o)
+			if err == nil {
+				return sm, nil
+			}
+			log.Log.Info("Failed to resume plugin session", "plugin", register.Name, "session", sm.sessionID, "error", err.Error())
+			sm.close(err)
+		}
+	}
+
+	sender := newSender(stream, o)
 	sm := &StreamManager{
-		stream:       stream,
-		pluginName:   register.Name,
-		pluginVer:    register.Version,
-		pendingCalls: make(map[string]chan interface{}),
+		pluginName:      register.Name,
+		pluginVer:       register.Version,
+		attached:        newAttachedStream(stream),
+		caller:          newCaller(sender, "plugin "+register.Name, o),
+		callbacks:       callbacks,
+		served:          newHandlerConn(sender),
+		chunks:          newReassembler(o.maxPayloadSize),
+		sessionID:       randomID(),
+		features:        negotiateFeatures(register.GetFeatures(), o.operatorFeatures()),
+		methods:         register.GetMethods(),
+		fileDescriptors: fileDescriptors,
+	}
+	sm.check = sm.checkMethod
+	sm.lastReceived.Store(time.Now().UnixNano())
+	if sm.hasFeature(FeatureNotifications) {
+		sm.notificationHandler = o.notificationHandler
+	}
+	sm.streaming = sm.hasFeature(FeatureStreaming)
+	sm.callbackCtx, sm.stopCallbacks = context.WithCancel(context.WithValue(context.Background(), pluginKey{}, sm))
+	if sm.hasFeature(FeatureCompression) {
+		sm.compression = negotiateCompression(o.compression, register.GetCompression())
+	}
+	if !sm.hasFeature(FeaturePayloadChunks) {
+		sm.sender.maxChunkSize = 0
+	}
+	if sm.hasFeature(FeatureSessionResumption) {
+		sm.session = &sessionLog{}
+		sm.sender.session = sm.session
+		sm.sessions = o.sessions
+		sm.resumeToken = randomID()
+		sm.sessions.add(sm.resumeToken, sm)
 	}
 
+	if acknowledge {
+		// The writer is not started yet, so the stream can be written directly
+		if err := stream.Send(newRegisterAckMessage(sm.registerAck(register, o))); err != nil {
+			sm.close(err)
+			return nil, fmt.Errorf("failed to send registration acknowledgement: %w", err)
+		}
+	}
+	sm.sender.start()
+
 	return sm, nil
 }
 
+// registerAck builds the acknowledgement accepting the registration of the plugin.
+func (sm *StreamManager) registerAck(register *pluginframeworkv1.PluginRegister, o *options) *pluginframeworkv1.PluginRegisterAck {
+	ack := &pluginframeworkv1.PluginRegisterAck{
+		SessionId:       sm.sessionID,
+		ProtocolVersion: min(register.GetProtocolVersion(), ProtocolVersion),
+		Features:        sm.features,
+		Limits: &pluginframeworkv1.PluginServerLimits{
+			MaxMessageSize: uint32(min(o.maxMessageSize, math.MaxUint32)),
+			MaxPayloadSize: uint32(min(o.maxPayloadSize, math.MaxUint32)),
+		},
+	}
+	if sm.hasFeature(FeatureOperatorServices) {
+		ack.Methods = serviceMethods(sm.callbacks.services.descs())
+	}
+	if sm.session != nil {
+		ack.ResumeToken = sm.resumeToken
+		ack.ResumeGracePeriod = durationpb.New(sm.sessions.gracePeriod)
+	}
+	return ack
+}
+
+// pluginKey is the context key of the StreamManager of a plugin calling a callback service.
+type pluginKey struct{}
+
+// PluginFromContext returns the StreamManager of the plugin calling a callback service
+// of the operator, from the context of the handler. See WithCallbackService.
+func PluginFromContext(ctx context.Context) (*StreamManager, bool) {
+	sm, ok := ctx.Value(pluginKey{}).(*StreamManager)
+	return sm, ok
+}
+
 // GetPluginName returns the name of the registered plugin.
 func (sm *StreamManager) GetPluginName() string {
 	return sm.pluginName
@@ -82,136 +240,348 @@ func (sm *StreamManager) GetPluginVersion() string {
 	return sm.pluginVer
 }
 
-// CallRPC sends an RPC call to the plugin and waits for the response.
-// The method name and payload are protocol-specific (e.g., "RenewToken" with RenewTokenRequest).
-// The response is returned as raw bytes that must be unmarshaled by the caller.
-func (sm *StreamManager) CallRPC(ctx context.Context, method string, reqPayload proto.Message) ([]byte, error) {
-	// Marshal request
-	reqBytes, err := proto.Marshal(reqPayload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+// GetSessionID returns the ID of the plugin session, sent to the plugin at registration.
+func (sm *StreamManager) GetSessionID() string {
+	return sm.sessionID
+}
+
+// LastMessageTime returns the time the last message was received from the plugin,
+// or the time it registered if none was received since.
+func (sm *StreamManager) LastMessageTime() time.Time {
+	return time.Unix(0, sm.lastReceived.Load())
+}
+
+// GetFeatures returns the protocol features used on the stream.
+func (sm *StreamManager) GetFeatures() []string {
+	return slices.Clone(sm.features)
+}
+
+// Name returns the name of the registered plugin.
+// It makes the StreamManager a registry.PluginProvider.
+func (sm *StreamManager) Name() string {
+	return sm.pluginName
+}
+
+// Methods returns the full names of the methods advertised by the plugin
+// ("/package.Service/Method"). It is empty for plugins that advertise none.
+func (sm *StreamManager) Methods() []string {
+	sm.methodsMu.RLock()
+	defer sm.methodsMu.RUnlock()
+
+	return slices.Clone(sm.methods)
+}
 
-	// Send RPC call
-	requestID := generateRequestID()
-	rpcCall := &pluginframeworkv1.PluginRPCCall{
-		RequestId: requestID,
-		Method:    method,
-		Payload:   reqBytes,
+// GetFileDescriptors returns the file descriptors of the services of the plugin and
+// their dependencies, when the plugin shares them (see WithFileDescriptors).
+func (sm *StreamManager) GetFileDescriptors() []*descriptorpb.FileDescriptorProto {
+	sm.methodsMu.RLock()
+	defer sm.methodsMu.RUnlock()
+
+	return slices.Clone(sm.fileDescriptors)
+}
+
+// checkMethod fails with UNIMPLEMENTED when the plugin advertised its methods
+// and method is not one of them, so that the call is not sent.
+func (sm *StreamManager) checkMethod(method string) error {
+	sm.methodsMu.RLock()
+	defer sm.methodsMu.RUnlock()
+
+	if len(sm.methods) == 0 || implementsMethod(sm.methods, method) {
+		return nil
 	}
+	return status.Errorf(codes.Unimplemented, "method %s is not implemented by plugin %s", method, sm.pluginName)
+}
 
-	msg := &pluginframeworkv1.PluginStreamMessage{
-		Payload: &pluginframeworkv1.PluginStreamMessage_RpcCall{
-			RpcCall: rpcCall,
-		},
+// handleServicesUpdate adds the services registered by the plugin after its registration.
+func (sm *StreamManager) handleServicesUpdate(update *pluginframeworkv1.PluginServicesUpdate) {
+	fds, err := parseFileDescriptors(update.GetFileDescriptors())
+	if err != nil {
+		log.Log.Error(err, "Ignoring file descriptors of plugin services", "plugin", sm.pluginName)
 	}
 
-	if err := sm.stream.Send(msg); err != nil {
-		return nil, fmt.Errorf("failed to send RPC call: %w", err)
+	sm.methodsMu.Lock()
+	defer sm.methodsMu.Unlock()
+
+	sm.methods = append(sm.methods, update.GetMethods()...)
+	sm.fileDescriptors = append(sm.fileDescriptors, fds...)
+}
+
+// handleNotification passes a notification of the plugin to the notification handler.
+// Notifications not naming an object are ignored.
+func (sm *StreamManager) handleNotification(notification *pluginframeworkv1.PluginNotification) {
+	if sm.notificationHandler == nil || notification.GetName() == "" {
+		log.Log.V(1).Info("Ignoring plugin notification", "plugin", sm.pluginName)
+		return
 	}
+	sm.notificationHandler(sm.callbackCtx, notification)
+}
 
-	// Wait for response
-	resp, err := sm.waitForResponse(ctx, requestID)
-	if err != nil {
+// hasFeature reports whether the plugin and the operator both support feature.
+func (sm *StreamManager) hasFeature(feature string) bool {
+	return slices.Contains(sm.features, feature)
+}
+
+// CallRPC sends an RPC call to the plugin and waits for the response.
+// The method name and payload are protocol-specific (e.g., "RenewToken" with RenewTokenRequest).
+// The response is returned as raw bytes that must be unmarshaled by the caller.
+//
+// Outgoing metadata attached to ctx is forwarded to the plugin handler.
+// The deadline of ctx, if any, is applied to the handler context, and the handler
+// context is cancelled when ctx is done before the response arrives.
+// Use grpc.Header and grpc.Trailer call options to receive the metadata set by the handler.
+//
+// Failed calls are retried according to the retry policy of the method (see WithRetryPolicy),
+// within the deadline of ctx. Use the IdempotencyKey call option to set the key shared
+// by the attempts.
+//
+// Calls to a method the plugin did not advertise fail with UNIMPLEMENTED without being sent.
+//
+// Calls go through the client interceptors (see WithUnaryClientInterceptors), which get
+// an opaque reply: use Invoke for interceptors inspecting the response.
+//
+// Errors are status errors: the status returned by the plugin handler is preserved,
+// so status.Code(err) can be compared with the code the handler used.
+func (sm *StreamManager) CallRPC(ctx context.Context, method string, reqPayload proto.Message, opts ...grpc.CallOption) ([]byte, error) {
+	reply := &rawReply{}
+	if err := sm.Invoke(ctx, method, reqPayload, reply, opts...); err != nil {
 		return nil, err
 	}
+	return reply.payload, nil
+}
 
-	respBytes, ok := resp.([]byte)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type: %T", resp)
-	}
+// rawReply receives the encoded response of a call made with CallRPC.
+type rawReply struct {
+	payload []byte
+}
 
-	return respBytes, nil
+// Invoke performs a unary RPC through the plugin stream.
+// Together with NewStream, it makes the StreamManager a grpc.ClientConnInterface,
+// so generated gRPC clients can be used directly: pb.NewMyServiceClient(sm).
+// The call goes through the client interceptors, which get a nil *grpc.ClientConn.
+func (sm *StreamManager) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return sm.caller.Invoke(ctx, method, args, reply, opts...)
 }
 
-// ListenForMessages listens for incoming messages from the plugin (responses and errors).
+// ListenForMessages listens for incoming messages from the plugin: responses and errors,
+// and the calls of the plugin to the callback services of the operator.
 // This should be run in a goroutine to continuously process plugin messages.
-// It returns when the stream is closed or an error occurs.
-func (sm *StreamManager) ListenForMessages(ctx context.Context) error {
+// It returns when the stream is closed or an error occurs, including ErrSlowConsumer
+// when the plugin stopped reading: the caller should then end the stream.
+//
+// Once it returned, pending and new calls fail right away with Unavailable, unless the
+// session is resumable (see WithSessionStore): they then wait for the plugin to resume
+// the session, and fail if it does not within the grace period.
+func (sm *StreamManager) ListenForMessages(ctx context.Context) (err error) {
+	attached := sm.attachedStream()
+	defer close(attached.released)
+
+	err = sm.listen(ctx, attached)
+	if !sm.suspend(err) {
+		sm.close(err)
+	}
+	return err
+}
+
+// listen handles the messages received on the attached stream until it fails,
+// ctx is done or the session is resumed on another stream.
+func (sm *StreamManager) listen(ctx context.Context, attached *attachedStream) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	msgs, recvErr := receive(attached.stream, stop)
+
+	// Acknowledge the messages received in a resumable session
+	var acks <-chan time.Time
+	if sm.session != nil {
+		ticker := time.NewTicker(ackInterval)
+		defer ticker.Stop()
+		acks = ticker.C
+	}
+
 	for {
+		var msg *pluginframeworkv1.PluginStreamMessage
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
-		}
-
-		msg, err := sm.stream.Recv()
-		if err != nil {
+		case <-attached.replaced:
+			return errStreamReplaced
+		case <-sm.sender.done:
+			return fmt.Errorf("failed to send message to plugin: %w", sm.sender.err)
+		case err := <-recvErr:
 			return fmt.Errorf("failed to receive message from plugin: %w", err)
+		case <-acks:
+			sm.sendSessionAck()
+			continue
+		case msg = <-msgs:
 		}
+		sm.lastReceived.Store(time.Now().UnixNano())
 
-		// Handle response message
-		resp := msg.GetRpcResponse()
-		if resp != nil {
-			if err := sm.handleResponse(resp); err != nil {
-				return fmt.Errorf("failed to handle RPC response: %w", err)
+		if sm.session != nil {
+			fresh, ackDue := sm.session.receive(msg)
+			if !fresh {
+				continue
+			}
+			if ackDue {
+				sm.sendSessionAck()
+			}
+			if ack := msg.GetSessionAck(); ack != nil {
+				sm.session.acknowledge(ack.GetSequence())
+				continue
 			}
 		}
 
-		// Handle error message
-		errMsg := msg.GetError()
-		if errMsg != nil {
-			sm.handleError(errMsg)
+		// Collect payload chunks, and restore the payload of the message they precede
+		if chunk := msg.GetPayloadChunk(); chunk != nil {
+			sm.chunks.add(chunk)
+			continue
+		}
+		if requestID, err := sm.chunks.assemble(msg); err != nil {
+			sm.rejectMessage(msg, requestID, err)
+			continue
+		}
+		compression, err := decompressPayload(msg, sm.sender.maxPayloadSize)
+		if err != nil {
+			ref, _ := payloadFields(msg)
+			sm.rejectMessage(msg, ref.requestID, err)
+			continue
+		}
+
+		switch {
+		case msg.GetRpcResponse() != nil:
+			sm.handleResponse(msg.GetRpcResponse())
+		case msg.GetError() != nil:
+			sm.handleError(msg.GetError())
+		case msg.GetRpcCall() != nil:
+			sm.callbacks.serveCall(sm.callbackCtx, sm.served, msg.GetRpcCall(), compression)
+		case isPluginRequest(msg.GetStreamFrame().GetRequestId()),
+			isPluginRequest(msg.GetStreamWindowUpdate().GetRequestId()),
+			msg.GetStreamHalfClose() != nil, msg.GetCancel() != nil:
+			// Messages of the calls of the plugin
+			sm.served.handleCallMessage(msg)
+		case msg.GetStreamHeader() != nil, msg.GetStreamFrame() != nil, msg.GetStreamEnd() != nil,
+			msg.GetStreamWindowUpdate() != nil:
+			sm.handleStreamMessage(msg)
+		case msg.GetServicesUpdate() != nil:
+			sm.handleServicesUpdate(msg.GetServicesUpdate())
+		case msg.GetNotification() != nil:
+			sm.handleNotification(msg.GetNotification())
 		}
 	}
 }
 
-// waitForResponse waits for an RPC response with the given request ID.
-func (sm *StreamManager) waitForResponse(ctx context.Context, requestID string) (interface{}, error) {
-	// Create channel for this request
-	respChan := make(chan interface{}, 1)
+// rejectMessage fails the call of a message whose payload could not be received.
+func (sm *StreamManager) rejectMessage(msg *pluginframeworkv1.PluginStreamMessage, requestID string, err error) {
+	if isPluginRequest(requestID) {
+		sm.served.rejectMessage(msg, requestID, err)
+		return
+	}
+	sm.failCall(requestID, err)
+}
 
-	sm.requestsMu.Lock()
-	sm.pendingCalls[requestID] = respChan
-	sm.requestsMu.Unlock()
+// sendSessionAck acknowledges the messages received since the last acknowledgement.
+func (sm *StreamManager) sendSessionAck() {
+	if msg := sm.session.ackMessage(); msg != nil {
+		_ = sm.sender.sendControl(msg)
+	}
+}
 
-	defer func() {
-		sm.requestsMu.Lock()
-		delete(sm.pendingCalls, requestID)
-		sm.requestsMu.Unlock()
-	}()
+// attachedStream is a stream serving the session of a StreamManager.
+type attachedStream struct {
+	stream StreamInterface
 
-	select {
-	case resp := <-respChan:
-		if err, ok := resp.(error); ok {
-			return nil, err
-		}
-		return resp, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	replaceOnce sync.Once
+	replaced    chan struct{} // closed when the plugin resumed the session on another stream
+	released    chan struct{} // closed once ListenForMessages left the stream
+}
+
+func newAttachedStream(stream StreamInterface) *attachedStream {
+	return &attachedStream{
+		stream:   stream,
+		replaced: make(chan struct{}),
+		released: make(chan struct{}),
 	}
 }
 
-// handleResponse processes an RPC response from the plugin.
-func (sm *StreamManager) handleResponse(rpcResp *pluginframeworkv1.PluginRPCResponse) error {
-	requestID := rpcResp.GetRequestId()
+// attachedStream returns the stream currently serving the session.
+func (sm *StreamManager) attachedStream() *attachedStream {
+	sm.attachMu.Lock()
+	defer sm.attachMu.Unlock()
 
-	sm.requestsMu.RLock()
-	respChan, exists := sm.pendingCalls[requestID]
-	sm.requestsMu.RUnlock()
+	return sm.attached
+}
 
-	if !exists {
-		return fmt.Errorf("no pending call for request ID: %s", requestID)
+// suspend keeps a resumable session for the plugin to resume it, after its stream failed
+// with err, and reports whether it did. Sessions ended by the plugin or by a slow consumer
+// are not kept. The session is closed if not resumed within the grace period.
+func (sm *StreamManager) suspend(err error) bool {
+	if sm.session == nil || errors.Is(err, io.EOF) || !sm.sender.detach() {
+		return false
 	}
 
-	// Return raw bytes - caller is responsible for unmarshaling
+	grace := sm.sessions.gracePeriod
+	sm.attachMu.Lock()
+	sm.expiry = time.AfterFunc(grace, func() {
+		sm.close(status.Errorf(codes.Unavailable, "plugin %s did not resume its session within %v", sm.pluginName, grace))
+	})
+	sm.attachMu.Unlock()
+
+	log.Log.Info("Plugin session suspended", "plugin", sm.pluginName, "session", sm.sessionID, "reason", err.Error())
+	return true
+}
+
+// resume attaches stream to the session, for a plugin registering with its resume token.
+// The stream previously attached is released first, in case its loss was not detected yet.
+// The messages the plugin did not receive are then sent again.
+func (sm *StreamManager) resume(stream StreamInterface, register *pluginframeworkv1.PluginRegister, o *options) error {
+	sm.resumeMu.Lock()
+	defer sm.resumeMu.Unlock()
+
+	previous := sm.attachedStream()
+	previous.replaceOnce.Do(func() { close(previous.replaced) })
+	timer := time.NewTimer(sm.sender.stallTimeout)
+	defer timer.Stop()
 	select {
-	case respChan <- rpcResp.GetPayload():
-		// Response sent to waiter
-	default:
-		// Channel full, cannot send response
+	case <-previous.released:
+	case <-timer.C:
+		return fmt.Errorf("previous stream of session %s was not released", sm.sessionID)
 	}
 
-	return nil
-}
+	sm.attachMu.Lock()
+	defer sm.attachMu.Unlock()
+
+	if sm.expiry == nil || !sm.expiry.Stop() || sm.closed() {
+		return fmt.Errorf("session %s is closed", sm.sessionID)
+	}
+	replay, err := sm.session.replay(register.GetResumeSequence())
+	if err != nil {
+		return err
+	}
 
-// handleError processes an error message from the plugin.
-func (sm *StreamManager) handleError(errMsg *pluginframeworkv1.PluginError) {
-	// If we can identify which request this error is for, we should send it to that waiter
-	// For now, we just log it
+	ack := sm.registerAck(register, o)
+	ack.Resumed = true
+	ack.ResumeSequence = sm.session.lastReceived()
+	// The writer is paused, so the stream can be written directly
+	if err := stream.Send(newRegisterAckMessage(ack)); err != nil {
+		return fmt.Errorf("failed to send registration acknowledgement: %w", err)
+	}
+
+	sm.expiry = nil
+	sm.attached = newAttachedStream(stream)
+	if !sm.sender.attach(stream, replay) {
+		return fmt.Errorf("session %s is closed", sm.sessionID)
+	}
+
+	log.Log.Info("Plugin session resumed", "plugin", sm.pluginName, "session", sm.sessionID, "replayed", len(replay))
+	return nil
 }
 
-// generateRequestID generates a unique request ID.
-func generateRequestID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+// close marks the stream as lost: the sender stops, pending calls and streams
+// complete with Unavailable instead of waiting for their deadline, and the handlers
+// of the calls of the plugin are cancelled.
+func (sm *StreamManager) close(cause error) {
+	sm.sender.fail(cause)
+	if sm.sessions != nil {
+		sm.sessions.remove(sm.resumeToken)
+	}
+	sm.closeCalls(cause)
+	sm.stopCallbacks()
 }
@@ -0,0 +1,157 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// metadataToProto converts gRPC metadata to its wire representation.
+// Keys are sorted so that the encoding is deterministic.
+func metadataToProto(md metadata.MD) []*pluginframeworkv1.MetadataEntry {
+	if len(md) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]*pluginframeworkv1.MetadataEntry, 0, len(keys))
+	for _, k := range keys {
+		values := make([][]byte, 0, len(md[k]))
+		for _, v := range md[k] {
+			values = append(values, []byte(v))
+		}
+		entries = append(entries, &pluginframeworkv1.MetadataEntry{
+			Key:    k,
+			Values: values,
+		})
+	}
+	return entries
+}
+
+// metadataFromProto converts the wire representation back to gRPC metadata.
+func metadataFromProto(entries []*pluginframeworkv1.MetadataEntry) metadata.MD {
+	md := metadata.MD{}
+	for _, e := range entries {
+		for _, v := range e.GetValues() {
+			md.Append(e.GetKey(), string(v))
+		}
+	}
+	return md
+}
+
+// applyCallOptions delivers the header and trailer of a completed call to the
+// grpc.Header and grpc.Trailer call options, if any were given.
+func applyCallOptions(opts []grpc.CallOption, header, trailer metadata.MD) {
+	for _, o := range opts {
+		switch o := o.(type) {
+		case grpc.HeaderCallOption:
+			*o.HeaderAddr = header
+		case grpc.TrailerCallOption:
+			*o.TrailerAddr = trailer
+		}
+	}
+}
+
+// errHeaderAlreadySent is returned when a handler changes the header after it was sent.
+var errHeaderAlreadySent = errors.New("header metadata already sent")
+
+// serverTransportStream collects header and trailer metadata set by a plugin
+// handler through grpc.SetHeader, grpc.SendHeader and grpc.SetTrailer.
+type serverTransportStream struct {
+	method string
+
+	// sendHeader, if set, sends the header as soon as it is frozen (streaming calls).
+	// Unary calls leave it nil: their header travels with the response.
+	sendHeader func(metadata.MD) error
+
+	mu         sync.Mutex
+	header     metadata.MD
+	trailer    metadata.MD
+	headerSent bool
+}
+
+var _ grpc.ServerTransportStream = (*serverTransportStream)(nil)
+
+func (s *serverTransportStream) Method() string {
+	return s.method
+}
+
+func (s *serverTransportStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.headerSent {
+		return errHeaderAlreadySent
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// SendHeader freezes the header and sends it for streaming calls.
+// For unary calls the header travels with the response, so it is only frozen here.
+func (s *serverTransportStream) SendHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.headerSent {
+		return errHeaderAlreadySent
+	}
+	s.header = metadata.Join(s.header, md)
+	s.headerSent = true
+
+	if s.sendHeader != nil {
+		return s.sendHeader(s.header)
+	}
+	return nil
+}
+
+// flushHeader sends the header if it was not sent yet.
+// Streaming calls always send a header before their first message.
+func (s *serverTransportStream) flushHeader() error {
+	if err := s.SendHeader(nil); err != nil && err != errHeaderAlreadySent {
+		return err
+	}
+	return nil
+}
+
+func (s *serverTransportStream) SetTrailer(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// collected returns the header and trailer set by the handler.
+func (s *serverTransportStream) collected() (metadata.MD, metadata.MD) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.header, s.trailer
+}
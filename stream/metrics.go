@@ -0,0 +1,48 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// payloadBytes counts the payload bytes before and after compression.
+	payloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "plugin_stream_compression_bytes_total",
+		Help: "Payload bytes compressed on the plugin stream, before (stage=uncompressed) and after (stage=compressed) compression.",
+	}, []string{"algorithm", "stage"})
+
+	// compressionRatio observes the compressed to uncompressed size ratio of each payload.
+	compressionRatio = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "plugin_stream_compression_ratio",
+		Help:    "Ratio of compressed to uncompressed payload size on the plugin stream.",
+		Buckets: []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+	}, []string{"algorithm"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(payloadBytes, compressionRatio)
+}
+
+// observeCompression records the compression of a payload of size bytes to compressed bytes.
+func observeCompression(algorithm string, size, compressed int) {
+	payloadBytes.WithLabelValues(algorithm, "uncompressed").Add(float64(size))
+	payloadBytes.WithLabelValues(algorithm, "compressed").Add(float64(compressed))
+	compressionRatio.WithLabelValues(algorithm).Observe(float64(compressed) / float64(size))
+}
@@ -0,0 +1,409 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// Option is a functional option for StreamManager and PluginStreamClient configuration.
+// Options that only make sense on one side of the stream are ignored by the other.
+type Option func(*options)
+
+// options holds the settings of both sides of the plugin stream.
+type options struct {
+	// Outbound messages
+	sendQueueSize       int
+	slowConsumerTimeout time.Duration
+
+	// Payloads, in both directions
+	maxChunkSize   int
+	maxPayloadSize int
+
+	// Operator-side registration
+	admission      func(*pluginframeworkv1.PluginRegister) error
+	maxMessageSize int
+	sessions       *SessionStore
+
+	// Operator-side services called by the plugin
+	callbackServices []serviceRegistration
+
+	// Operator-side handler of the notifications of the plugin, disabled when nil
+	notificationHandler NotificationHandler
+
+	// Plugin-side registration
+	registerTimeout time.Duration
+	fileDescriptors bool
+
+	// Plugin-side reconnection, disabled when dialer is nil
+	dialer          Dialer
+	reconnectPolicy ReconnectPolicy
+	stateHandler    ConnectionStateHandler
+
+	// Plugin-side services served besides the one given to NewPluginStreamClient
+	services []serviceRegistration
+
+	// Handler middleware, of the plugin services and of the operator callback services
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	recoveryHandler    RecoveryHandler
+
+	// Operator-side payload compression, by order of preference
+	compression          []string
+	compressionThreshold int
+
+	// Retries of the calls to the peer, by full or short method name ("" for the default)
+	retryPolicies map[string]RetryPolicy
+
+	// Middleware of the calls to the peer
+	unaryClientInterceptors  []grpc.UnaryClientInterceptor
+	streamClientInterceptors []grpc.StreamClientInterceptor
+
+	// Plugin-side handler limits
+	maxConcurrentCalls int
+	maxQueuedCalls     int
+	methodLimits       map[string]int
+
+	// Plugin-side idempotency cache, disabled when idempotencyTTL is zero
+	idempotencyTTL        time.Duration
+	idempotencyMaxEntries int
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		sendQueueSize:        defaultSendQueueSize,
+		slowConsumerTimeout:  defaultSlowConsumerTimeout,
+		maxChunkSize:         defaultMaxChunkSize,
+		maxPayloadSize:       defaultMaxPayloadSize,
+		maxMessageSize:       defaultMaxMessageSize,
+		registerTimeout:      defaultRegisterTimeout,
+		compressionThreshold: defaultCompressionThreshold,
+		retryPolicies:        make(map[string]RetryPolicy),
+		maxQueuedCalls:       defaultMaxQueuedCalls,
+		methodLimits:         make(map[string]int),
+		recoveryHandler:      defaultRecoveryHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSendQueueSize sets how many outbound messages may wait for the writer goroutine.
+// Senders block while the queue is full. The default is 256.
+func WithSendQueueSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.sendQueueSize = size
+		}
+	}
+}
+
+// WithSlowConsumerTimeout sets how long writing a single message to the stream may block
+// before the peer is considered to have stopped reading. The stream is then abandoned:
+// ListenForMessages or HandleRPCCalls returns ErrSlowConsumer. The default is 30 seconds.
+func WithSlowConsumerTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.slowConsumerTimeout = timeout
+		}
+	}
+}
+
+// WithMaxChunkSize sets the largest payload sent in a single message. Larger payloads
+// are split in chunks, which calls running at the same time interleave with their own
+// messages. It must leave room for the message envelope under the message size limit
+// of the peer. The default is 1 MiB.
+func WithMaxChunkSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxChunkSize = size
+		}
+	}
+}
+
+// WithMaxPayloadSize sets the largest request, response or stream message accepted
+// or sent by a call. Calls over the limit fail with RESOURCE_EXHAUSTED.
+// The default is 64 MiB.
+func WithMaxPayloadSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxPayloadSize = size
+		}
+	}
+}
+
+// WithAdmission sets the function deciding whether a plugin may register.
+// When it returns an error, the plugin gets a PluginRegisterAck carrying the status
+// of the error (see status.Convert), and NewStreamManager returns the error.
+func WithAdmission(admit func(register *pluginframeworkv1.PluginRegister) error) Option {
+	return func(o *options) {
+		o.admission = admit
+	}
+}
+
+// WithMaxMessageSize sets the largest stream message the operator receives, as configured
+// on its gRPC server. It is announced to the plugin at registration, which keeps its
+// payload chunks under it. The default is 4 MiB, the gRPC default.
+func WithMaxMessageSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxMessageSize = size
+		}
+	}
+}
+
+// WithRegisterTimeout sets how long a plugin waits for the operator to acknowledge
+// its registration. The default is 30 seconds.
+func WithRegisterTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.registerTimeout = timeout
+		}
+	}
+}
+
+// serviceRegistration is a service added with WithService.
+type serviceRegistration struct {
+	desc *grpc.ServiceDesc
+	impl any
+}
+
+// WithService makes the plugin serve another service on the same stream, implemented
+// by impl. Calls are routed by full method name ("/package.Service/Method"), so that
+// services may have methods of the same name. Registering a service twice fails.
+func WithService(desc *grpc.ServiceDesc, impl any) Option {
+	return func(o *options) {
+		o.services = append(o.services, serviceRegistration{desc: desc, impl: impl})
+	}
+}
+
+// WithUnaryInterceptors adds interceptors around the unary handlers of the plugin, or of the
+// callback services of the operator (see WithCallbackService),
+// the first being the outermost, as grpc.ChainUnaryInterceptor does for a grpc.Server.
+// Panics of the interceptors and handlers are recovered, see WithRecoveryHandler.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(o *options) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds interceptors around the streaming handlers of the plugin, or
+// of the callback services of the operator (see WithCallbackService),
+// the first being the outermost, as grpc.ChainStreamInterceptor does for a grpc.Server.
+// Panics of the interceptors and handlers are recovered, see WithRecoveryHandler.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(o *options) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithRecoveryHandler sets how a panic of a plugin handler or interceptor is reported
+// to the operator. By default, the panic is logged with its stack trace and the call
+// fails with INTERNAL; the plugin keeps serving other calls either way.
+func WithRecoveryHandler(handler RecoveryHandler) Option {
+	return func(o *options) {
+		if handler != nil {
+			o.recoveryHandler = handler
+		}
+	}
+}
+
+// WithFileDescriptors makes the plugin send the file descriptors of its services and
+// their dependencies at registration, so that the operator can inspect the messages
+// of the methods (see StreamManager.GetFileDescriptors). The services must be declared
+// in generated files linked in the plugin.
+func WithFileDescriptors() Option {
+	return func(o *options) {
+		o.fileDescriptors = true
+	}
+}
+
+// WithCompression sets the algorithms the operator may compress payloads with,
+// by order of preference (CompressionZstd, CompressionGzip). The first one the plugin
+// accepted at registration is used for its calls, and the plugin compresses its
+// responses and stream messages with the algorithm of the call.
+// Payloads are not compressed by default.
+//
+// Compression helps most with large, repetitive payloads such as rendered manifests,
+// especially through a BidiStreamAdapter, which wraps every message once more.
+func WithCompression(algorithms ...string) Option {
+	return func(o *options) {
+		o.compression = algorithms
+	}
+}
+
+// WithCompressionThreshold sets the payload size, in bytes, under which payloads are
+// sent uncompressed. The default is 1 KiB.
+func WithCompressionThreshold(size int) Option {
+	return func(o *options) {
+		if size >= 0 {
+			o.compressionThreshold = size
+		}
+	}
+}
+
+// WithRetryPolicy sets the retry policy of unary calls to a plugin method, or, on the
+// plugin side, to a method of the callback services of the operator.
+// The method is either a full method name ("/package.Service/Method"), a method name,
+// or empty to set the policy of every method without its own.
+// Calls are not retried by default.
+func WithRetryPolicy(method string, policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicies[method] = policy
+	}
+}
+
+// WithUnaryClientInterceptors adds interceptors around the unary calls made to the plugin
+// (or, on the plugin side, to the operator),
+// the first being the outermost, as grpc.WithChainUnaryInterceptor does for a grpc.ClientConn.
+// They run once per call; the attempts of a retry policy run inside them.
+// Use server.WithStreamOptions to apply them to every plugin of a server.
+func WithUnaryClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *options) {
+		o.unaryClientInterceptors = append(o.unaryClientInterceptors, interceptors...)
+	}
+}
+
+// WithStreamClientInterceptors adds interceptors around the streaming calls made to the plugin
+// (or, on the plugin side, to the operator),
+// the first being the outermost, as grpc.WithChainStreamInterceptor does for a grpc.ClientConn.
+// Use server.WithStreamOptions to apply them to every plugin of a server.
+func WithStreamClientInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *options) {
+		o.streamClientInterceptors = append(o.streamClientInterceptors, interceptors...)
+	}
+}
+
+// WithMaxConcurrentCalls sets the maximum number of RPC handlers running at once on the plugin.
+// Calls beyond the limit wait in a bounded queue (see WithMaxQueuedCalls).
+// The default is unlimited.
+func WithMaxConcurrentCalls(max int) Option {
+	return func(o *options) {
+		if max > 0 {
+			o.maxConcurrentCalls = max
+		}
+	}
+}
+
+// WithMaxQueuedCalls sets the maximum number of calls waiting for a handler slot on the plugin.
+// Calls arriving when the queue is full are rejected with RESOURCE_EXHAUSTED,
+// which the operator sees as a retryable error. Zero disables queueing. The default is 100.
+func WithMaxQueuedCalls(max int) Option {
+	return func(o *options) {
+		if max >= 0 {
+			o.maxQueuedCalls = max
+		}
+	}
+}
+
+// WithMethodConcurrencyLimit sets the maximum number of handlers running at once for a method.
+// The method is either a full method name ("/package.Service/Method") or a method name.
+func WithMethodConcurrencyLimit(method string, max int) Option {
+	return func(o *options) {
+		if max > 0 {
+			o.methodLimits[method] = max
+		}
+	}
+}
+
+// WithIdempotencyCache makes the plugin remember the responses of unary calls carrying
+// an idempotency key for ttl, and at most maxEntries of them (0 for no limit).
+// A call repeating the method and key of a remembered call gets the stored response
+// without running the handler; one arriving while the first call runs waits for it.
+// Only successful responses are stored, so that a failed call can be retried.
+func WithIdempotencyCache(ttl time.Duration, maxEntries int) Option {
+	return func(o *options) {
+		if ttl > 0 && maxEntries >= 0 {
+			o.idempotencyTTL = ttl
+			o.idempotencyMaxEntries = maxEntries
+		}
+	}
+}
+
+// WithSessionStore makes the sessions of plugins supporting it resumable, keeping them in store.
+// A plugin reconnecting within the grace period of store resumes its session: calls pending
+// on both sides complete on the new stream, and messages lost with the previous stream are
+// sent again. Meanwhile, calls wait for the plugin instead of failing with Unavailable.
+func WithSessionStore(store *SessionStore) Option {
+	return func(o *options) {
+		o.sessions = store
+	}
+}
+
+// WithReconnect makes the plugin reconnect when its stream to the operator fails:
+// HandleRPCCalls opens a new stream with dial and registers the plugin again, waiting
+// between attempts as set by policy, instead of returning the error. It gives up once
+// policy.MaxElapsedTime passed without success. client.New enables it by default.
+// When the operator supports it (see WithSessionStore), the plugin resumes its session,
+// and calls in flight survive the reconnection.
+func WithReconnect(dial Dialer, policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.dialer = dial
+		o.reconnectPolicy = policy
+	}
+}
+
+// WithConnectionStateHandler sets a function called by the plugin when its stream to the
+// operator is lost, when it reconnected, and when it gave up reconnecting.
+// It runs on the goroutine of HandleRPCCalls and must not block.
+func WithConnectionStateHandler(handler ConnectionStateHandler) Option {
+	return func(o *options) {
+		o.stateHandler = handler
+	}
+}
+
+// WithCallbackService makes the operator serve a callback service to its plugins, such as
+// a service reporting status or emitting events, implemented by impl. Plugins call it
+// over their stream through PluginStreamClient.OperatorConn; the handlers get the calling
+// plugin with PluginFromContext. Registering a service twice fails.
+// Use server.Server.RegisterService to serve it to every plugin of a server.
+func WithCallbackService(desc *grpc.ServiceDesc, impl any) Option {
+	return func(o *options) {
+		o.callbackServices = append(o.callbackServices, serviceRegistration{desc: desc, impl: impl})
+	}
+}
+
+// NotificationHandler handles a notification of a plugin asking the operator to reconcile
+// an object. The context gives the plugin with PluginFromContext and is cancelled when
+// its stream is closed. It runs on the goroutine reading the stream and must not block.
+type NotificationHandler func(ctx context.Context, notification *pluginframeworkv1.PluginNotification)
+
+// WithNotificationHandler makes the operator accept notifications from its plugins
+// (see PluginStreamClient.Notify), passed to handler.
+// Use server.Server.NotificationSource to turn them into reconcile requests.
+func WithNotificationHandler(handler NotificationHandler) Option {
+	return func(o *options) {
+		o.notificationHandler = handler
+	}
+}
+
+// callbackDispatcher creates the dispatcher of the callback services set with WithCallbackService.
+func (o *options) callbackDispatcher() (*dispatcher, error) {
+	d := newDispatcher(o)
+	for _, svc := range o.callbackServices {
+		if err := d.services.add(svc.desc, svc.impl); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
@@ -18,27 +18,78 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"path"
+	"slices"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
 )
 
 // PluginStreamClient manages the plugin side of the bidirectional stream.
 // It handles registration, receives RPC calls from the operator, and sends responses back.
+// The plugin calls the services of the operator through OperatorConn.
 type PluginStreamClient struct {
-	stream     StreamInterface
 	pluginName string
 	pluginVer  string
-	service    grpc.ServiceDesc
-	impl       any
+	opts       *options
+
+	// Handlers of the services served
+	*dispatcher
+	shareFiles bool
+
+	// Largest payload received; the one sent is lowered to the operator's limit
+	maxPayloadSize int
+
+	// Current connection to the operator, replaced when the plugin reconnects
+	connMu sync.RWMutex
+	conn   *pluginConn
+
+	// Errors advertising the services registered with RegisterService
+	advertiseMu  sync.Mutex
+	advertiseErr error
 }
 
-// NewPluginStreamClient creates a new PluginStreamClient and sends the registration message.
+var _ grpc.ServiceRegistrar = (*PluginStreamClient)(nil)
+
+// pluginConn is a session with the operator, served by a registered stream.
+// A resumable session moves to a new stream when the plugin reconnects.
+type pluginConn struct {
+	stream StreamInterface
+
+	// Calls of the operator in flight, answered through the single writer of the stream.
+	// Streaming calls are cancelled when the session is lost.
+	*handlerConn
+
+	// Calls to the services of the operator
+	caller *caller
+
+	// Payload chunks being received, kept across the streams of a session
+	chunks *reassembler
+
+	// Session negotiated at registration
+	sessionID string
+	features  []string
+
+	// Resumable session, nil if the operator does not support it
+	session     *sessionLog
+	resumeToken string
+
+	// Cancels the context of a stream opened by the dialer, nil otherwise
+	cancelStream context.CancelFunc
+}
+
+// NewPluginStreamClient creates a new PluginStreamClient serving service, and the services
+// added with WithService, sends the registration message and waits for the operator
+// to acknowledge it (see WithRegisterTimeout). service may be left empty, with a nil impl,
+// when the services are registered afterwards with RegisterService.
+// When the operator rejects the plugin, the error wraps the status of the rejection.
 func NewPluginStreamClient(
 	ctx context.Context,
 	stream StreamInterface,
@@ -46,119 +97,421 @@ func NewPluginStreamClient(
 	pluginVersion string,
 	service grpc.ServiceDesc,
 	impl any,
+	opts ...Option,
 ) (*PluginStreamClient, error) {
+	o := newOptions(opts)
 	psc := &PluginStreamClient{
-		stream:     stream,
-		pluginName: pluginName,
-		pluginVer:  pluginVersion,
-		service:    service,
-		impl:       impl,
+		pluginName:     pluginName,
+		pluginVer:      pluginVersion,
+		opts:           o,
+		dispatcher:     newDispatcher(o),
+		maxPayloadSize: o.maxPayloadSize,
+		shareFiles:     o.fileDescriptors,
+	}
+
+	if service.ServiceName != "" {
+		if err := psc.services.add(&service, impl); err != nil {
+			return nil, err
+		}
+	}
+	for _, svc := range o.services {
+		if err := psc.services.add(svc.desc, svc.impl); err != nil {
+			return nil, err
+		}
 	}
 
-	// Send registration message
-	registerMsg := &pluginframeworkv1.PluginStreamMessage{
-		Payload: &pluginframeworkv1.PluginStreamMessage_Register{
-			Register: &pluginframeworkv1.PluginRegister{
-				Name:    pluginName,
-				Version: pluginVersion,
-			},
-		},
+	conn, err := psc.register(ctx, stream, nil)
+	if err != nil {
+		return nil, err
 	}
+	psc.conn = conn
+
+	return psc, nil
+}
 
+// register sends the registration message of the plugin on stream, with every service
+// registered so far, and waits for the operator to acknowledge it.
+// The session of prev is resumed on stream when possible, and returned.
+func (psc *PluginStreamClient) register(ctx context.Context, stream StreamInterface, prev *pluginConn) (*pluginConn, error) {
+	registerMsg, err := newRegisterMessage(psc.pluginName, psc.pluginVer, psc.services.descs(), psc.opts)
+	if err != nil {
+		return nil, err
+	}
+	resuming := prev != nil && prev.resumeToken != ""
+	if resuming {
+		registerMsg.GetRegister().ResumeToken = prev.resumeToken
+		registerMsg.GetRegister().ResumeSequence = prev.session.lastReceived()
+	}
 	if err := stream.Send(registerMsg); err != nil {
 		return nil, fmt.Errorf("failed to send registration: %w", err)
 	}
 
-	return psc, nil
+	ack, err := receiveRegisterAck(ctx, stream, psc.opts.registerTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if resuming && ack.GetResumed() {
+		return prev, prev.resume(stream, ack)
+	}
+
+	conn := &pluginConn{
+		stream:      stream,
+		handlerConn: newHandlerConn(newSender(stream, psc.opts)),
+		chunks:      newReassembler(psc.maxPayloadSize),
+		sessionID:   ack.GetSessionId(),
+		features:    ack.GetFeatures(),
+	}
+	conn.caller = psc.newOperatorCaller(conn, ack)
+	if slices.Contains(conn.features, FeatureSessionResumption) && ack.GetResumeToken() != "" {
+		conn.session = &sessionLog{}
+		conn.sender.session = conn.session
+		conn.resumeToken = ack.GetResumeToken()
+	}
+
+	// Keep the messages sent under the limits of the operator
+	if !slices.Contains(conn.features, FeaturePayloadChunks) {
+		conn.sender.maxChunkSize = 0
+	} else if size := int(ack.GetLimits().GetMaxMessageSize()); size > chunkEnvelopeSize {
+		conn.sender.maxChunkSize = min(conn.sender.maxChunkSize, size-chunkEnvelopeSize)
+	}
+	if size := int(ack.GetLimits().GetMaxPayloadSize()); size > 0 {
+		conn.sender.maxPayloadSize = min(conn.sender.maxPayloadSize, size)
+	}
+	conn.sender.start()
+
+	return conn, nil
+}
+
+// resume moves the session to stream, on which the operator resumed it: the messages
+// it did not receive are sent again, before those queued since the previous stream was lost.
+// A session that cannot be resumed is not offered again.
+func (conn *pluginConn) resume(stream StreamInterface, ack *pluginframeworkv1.PluginRegisterAck) error {
+	replay, err := conn.session.replay(ack.GetResumeSequence())
+	if err != nil {
+		conn.resumeToken = ""
+		return fmt.Errorf("failed to resume session %s: %w", conn.sessionID, err)
+	}
+	if !conn.sender.attach(stream, replay) {
+		return fmt.Errorf("failed to resume session %s: %w", conn.sessionID, conn.sender.err)
+	}
+	conn.stream = stream
+	return nil
+}
+
+// sendSessionAck acknowledges the messages received since the last acknowledgement.
+func (conn *pluginConn) sendSessionAck() {
+	if msg := conn.session.ackMessage(); msg != nil {
+		_ = conn.sender.sendControl(msg)
+	}
+}
+
+// current returns the current connection to the operator.
+func (psc *PluginStreamClient) current() *pluginConn {
+	psc.connMu.RLock()
+	defer psc.connMu.RUnlock()
+
+	return psc.conn
+}
+
+// newOperatorCaller creates the caller of conn to the callback services of the operator,
+// which the operator advertised in ack.
+func (psc *PluginStreamClient) newOperatorCaller(conn *pluginConn, ack *pluginframeworkv1.PluginRegisterAck) *caller {
+	c := newCaller(conn.sender, "operator", psc.opts)
+	c.requestIDPrefix = pluginRequestPrefix
+	c.streaming = slices.Contains(conn.features, FeatureStreaming)
+
+	served := slices.Contains(conn.features, FeatureOperatorServices)
+	methods := ack.GetMethods()
+	c.check = func(method string) error {
+		if !served {
+			return status.Error(codes.Unimplemented, "operator does not serve callback services")
+		}
+		if !implementsMethod(methods, method) {
+			return status.Errorf(codes.Unimplemented, "method %s is not served by the operator", method)
+		}
+		return nil
+	}
+	return c
+}
+
+// OperatorConn returns a connection to the callback services of the operator
+// (see WithCallbackService), for generated gRPC clients:
+//
+//	status := pb.NewStatusReporterClient(psc.OperatorConn())
+//
+// Calls go through the current stream, with the client interceptors and retry policies
+// of the plugin. Calls in flight when the stream is lost fail with UNAVAILABLE, unless
+// the plugin resumes its session. Calls to a method the operator does not serve fail
+// with UNIMPLEMENTED without being sent.
+func (psc *PluginStreamClient) OperatorConn() grpc.ClientConnInterface {
+	return operatorConn{psc: psc}
+}
+
+// operatorConn sends the calls of the plugin on its current connection to the operator.
+type operatorConn struct {
+	psc *PluginStreamClient
+}
+
+func (oc operatorConn) Invoke(ctx context.Context, method string, args any, reply any, opts ...grpc.CallOption) error {
+	return oc.psc.current().caller.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (oc operatorConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return oc.psc.current().caller.NewStream(ctx, desc, method, opts...)
+}
+
+// RegisterService registers a service and its implementation, so that generated
+// Register*Server functions can be used with a PluginStreamClient:
+//
+//	pb.RegisterMyServiceServer(psc, &myServiceImpl{})
+//
+// As with grpc.Server, it panics when impl does not implement the service, or when the
+// service is already registered. The methods of the service are advertised to the operator,
+// which must support FeatureServiceUpdates to accept calls to them. Failures to advertise
+// them are logged, and reported by AdvertiseError.
+func (psc *PluginStreamClient) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	if err := psc.services.add(desc, impl); err != nil {
+		panic(fmt.Sprintf("stream: PluginStreamClient.RegisterService: %v", err))
+	}
+
+	logger := log.Log.WithValues("plugin", psc.pluginName, "service", desc.ServiceName)
+	conn := psc.current()
+	if !slices.Contains(conn.features, FeatureServiceUpdates) {
+		err := status.Errorf(codes.Unimplemented, "operator does not accept service updates, %s is not advertised", desc.ServiceName)
+		logger.Error(err, "Failed to advertise service")
+		psc.recordAdvertiseError(err)
+		return
+	}
+	msg, err := newServicesUpdateMessage([]*grpc.ServiceDesc{desc}, psc.shareFiles)
+	if err != nil {
+		logger.Error(err, "Advertising service without its file descriptors")
+		msg, _ = newServicesUpdateMessage([]*grpc.ServiceDesc{desc}, false)
+	}
+	// A plugin reconnecting advertises the service when registering again
+	if err := conn.sender.send(context.Background(), msg); err != nil {
+		err = fmt.Errorf("failed to advertise service %s: %w", desc.ServiceName, err)
+		logger.Error(err, "Failed to advertise service")
+		psc.recordAdvertiseError(err)
+	}
+}
+
+// AdvertiseError returns the errors advertising the services registered with
+// RegisterService to the operator, nil if they were all advertised.
+func (psc *PluginStreamClient) AdvertiseError() error {
+	psc.advertiseMu.Lock()
+	defer psc.advertiseMu.Unlock()
+
+	return psc.advertiseErr
+}
+
+func (psc *PluginStreamClient) recordAdvertiseError(err error) {
+	psc.advertiseMu.Lock()
+	defer psc.advertiseMu.Unlock()
+
+	psc.advertiseErr = errors.Join(psc.advertiseErr, err)
+}
+
+// Notify asks the operator to reconcile the object namespace/name (namespace is empty for
+// cluster-scoped objects), for instance when the plugin notices that the external resource
+// behind it changed. Reason explains why, for logs and authorization.
+//
+// Notifications are not acknowledged: the operator may drop those over its rate limit or
+// that the plugin is not allowed to send. Notify fails with UNIMPLEMENTED when the operator
+// does not accept notifications.
+func (psc *PluginStreamClient) Notify(ctx context.Context, namespace, name, reason string) error {
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "notification must name an object")
+	}
+
+	conn := psc.current()
+	if !slices.Contains(conn.features, FeatureNotifications) {
+		return status.Error(codes.Unimplemented, "operator does not accept notifications")
+	}
+	return conn.sender.send(ctx, newNotificationMessage(namespace, name, reason))
+}
+
+// GetSessionID returns the ID of the session given by the operator at registration.
+// It changes when the plugin reconnects.
+func (psc *PluginStreamClient) GetSessionID() string {
+	return psc.current().sessionID
+}
+
+// GetFeatures returns the protocol features accepted by the operator.
+func (psc *PluginStreamClient) GetFeatures() []string {
+	return slices.Clone(psc.current().features)
 }
 
 // HandleRPCCalls continuously listens for RPC calls from the operator and processes them using the handler.
 // This should be run in the main goroutine or as the primary loop of the plugin.
+// Each call runs in its own goroutine, within the limits set by WithMaxConcurrentCalls,
+// WithMaxQueuedCalls and WithMethodConcurrencyLimit.
+//
+// With WithReconnect, a failed stream is replaced by a new one, and HandleRPCCalls only
+// returns when ctx is done or when the plugin gave up reconnecting. Streaming calls of the
+// lost stream are cancelled; unary handlers run to completion, and their responses are
+// kept in the idempotency cache (see WithIdempotencyCache) for the operator to retry.
+//
+// Otherwise, it returns ErrSlowConsumer when the operator stopped reading the stream;
+// the connection should then be closed. No message can be sent once it returned.
 func (psc *PluginStreamClient) HandleRPCCalls(ctx context.Context) error {
+	conn := psc.current()
 	for {
+		err := psc.serve(ctx, conn)
+		if ctx.Err() != nil || psc.opts.dialer == nil {
+			return err
+		}
+
+		suspended := psc.suspend(conn)
+		psc.setState(StateReconnecting, err)
+		next, err := psc.reconnect(ctx, conn, err)
+		if err != nil {
+			psc.drop(conn)
+			psc.setState(StateDisconnected, err)
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if next != conn {
+			if suspended {
+				psc.drop(conn)
+			}
+			psc.connMu.Lock()
+			psc.conn = next
+			psc.connMu.Unlock()
+			conn = next
+		}
+		psc.setState(StateConnected, nil)
+	}
+}
+
+// serve handles the RPC calls received on conn until ctx is done or the stream fails.
+func (psc *PluginStreamClient) serve(ctx context.Context, conn *pluginConn) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	msgs, recvErr := receive(conn.stream, stop)
+
+	// Acknowledge the messages received in a resumable session
+	var acks <-chan time.Time
+	if conn.session != nil {
+		ticker := time.NewTicker(ackInterval)
+		defer ticker.Stop()
+		acks = ticker.C
+	}
+
+	for {
+		var msg *pluginframeworkv1.PluginStreamMessage
 		select {
 		case <-ctx.Done():
+			// Stop the writer before closing, CloseSend must not run concurrently with Send
+			if !conn.sender.stop(ctx.Err()) {
+				return ErrSlowConsumer
+			}
 			// Try to close the stream if it has a CloseSend method
-			if closer, ok := psc.stream.(interface{ CloseSend() error }); ok {
+			if closer, ok := conn.stream.(interface{ CloseSend() error }); ok {
 				return closer.CloseSend()
 			}
 			return nil
-		default:
+		case <-conn.sender.done:
+			return fmt.Errorf("failed to send message to operator: %w", conn.sender.err)
+		case err := <-recvErr:
+			if conn.session == nil {
+				conn.sender.fail(err)
+			}
+			return fmt.Errorf("failed to receive message: %w", err)
+		case <-acks:
+			conn.sendSessionAck()
+			continue
+		case msg = <-msgs:
 		}
 
-		msg, err := psc.stream.Recv()
+		if conn.session != nil {
+			fresh, ackDue := conn.session.receive(msg)
+			if !fresh {
+				continue
+			}
+			if ackDue {
+				conn.sendSessionAck()
+			}
+			if ack := msg.GetSessionAck(); ack != nil {
+				conn.session.acknowledge(ack.GetSequence())
+				continue
+			}
+		}
+
+		// Collect payload chunks, and restore the payload of the message they precede
+		if chunk := msg.GetPayloadChunk(); chunk != nil {
+			conn.chunks.add(chunk)
+			continue
+		}
+		if requestID, err := conn.chunks.assemble(msg); err != nil {
+			conn.rejectMessage(msg, requestID, err)
+			continue
+		}
+		compression, err := decompressPayload(msg, psc.maxPayloadSize)
 		if err != nil {
-			return fmt.Errorf("failed to receive message: %w", err)
+			ref, _ := payloadFields(msg)
+			conn.rejectMessage(msg, ref.requestID, err)
+			continue
 		}
 
-		// Handle RPC call
-		rpcCall := msg.GetRpcCall()
-		if rpcCall != nil {
-			go func() {
-				if err := psc.handleRPCCall(ctx, rpcCall); err != nil {
-					// Log the error since it's in a goroutine
-					// Note: In a real implementation, you might want to use a logger
-					fmt.Printf("Error handling RPC call: %v\n", err)
-				}
-			}()
+		switch {
+		case msg.GetRpcCall() != nil:
+			psc.serveCall(ctx, conn.handlerConn, msg.GetRpcCall(), compression)
+		case msg.GetRpcResponse() != nil:
+			conn.caller.handleResponse(msg.GetRpcResponse())
+		case msg.GetError() != nil:
+			conn.caller.handleError(msg.GetError())
+		case msg.GetStreamHeader() != nil, msg.GetStreamEnd() != nil:
+			conn.caller.handleStreamMessage(msg)
+		case isPluginRequest(msg.GetStreamFrame().GetRequestId()),
+			isPluginRequest(msg.GetStreamWindowUpdate().GetRequestId()):
+			// Stream messages of the calls of the plugin
+			conn.caller.handleStreamMessage(msg)
+		default:
+			conn.handleCallMessage(msg)
 		}
 	}
 }
 
-// handleRPCCall processes a single RPC call from the operator.
-func (psc *PluginStreamClient) handleRPCCall(ctx context.Context, rpcCall *pluginframeworkv1.PluginRPCCall) error {
-	requestID := rpcCall.GetRequestId()
-	fullMethod := rpcCall.GetMethod()
-	method := path.Base(fullMethod)
+// rejectMessage fails the call of a message whose payload could not be received.
+func (conn *pluginConn) rejectMessage(msg *pluginframeworkv1.PluginStreamMessage, requestID string, err error) {
+	if isPluginRequest(requestID) {
+		conn.caller.failCall(requestID, err)
+		return
+	}
+	conn.handlerConn.rejectMessage(msg, requestID, err)
+}
 
-	for _, m := range psc.service.Methods {
-		if m.MethodName == method {
-			dec := func(v interface{}) error {
-				return proto.Unmarshal(rpcCall.GetPayload(), v.(proto.Message))
-			}
-			out, err := m.Handler(psc.impl, ctx, dec, nil)
-			if err != nil {
-				msg := &pluginframeworkv1.PluginStreamMessage{
-					Payload: &pluginframeworkv1.PluginStreamMessage_Error{
-						Error: &pluginframeworkv1.PluginError{
-							Code:    "RPC_ERROR",
-							Message: err.Error(),
-						},
-					},
-				}
-				return psc.stream.Send(msg)
-			}
-			respBytes, err := proto.Marshal(out.(proto.Message))
-			if err != nil {
-				msg := &pluginframeworkv1.PluginStreamMessage{
-					Payload: &pluginframeworkv1.PluginStreamMessage_Error{
-						Error: &pluginframeworkv1.PluginError{
-							Code:    "MARSHAL_ERROR",
-							Message: fmt.Sprintf("failed to marshal response: %v", err),
-						},
-					},
-				}
-				return psc.stream.Send(msg)
-			}
-			msg := &pluginframeworkv1.PluginStreamMessage{
-				Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
-					RpcResponse: &pluginframeworkv1.PluginRPCResponse{
-						RequestId: requestID,
-						Payload:   respBytes,
-					},
-				},
-			}
-			return psc.stream.Send(msg)
-		}
+// suspend keeps the session of conn, whose stream failed, for the operator to resume it:
+// messages sent meanwhile are queued, and calls in flight go on. It reports false, after
+// dropping conn, when the session is not resumable.
+func (psc *PluginStreamClient) suspend(conn *pluginConn) bool {
+	if conn.resumeToken == "" || !conn.sender.detach() {
+		psc.drop(conn)
+		return false
 	}
+	if conn.cancelStream != nil {
+		conn.cancelStream()
+	}
+	return true
+}
 
-	msg := &pluginframeworkv1.PluginStreamMessage{
-		Payload: &pluginframeworkv1.PluginStreamMessage_Error{
-			Error: &pluginframeworkv1.PluginError{
-				Code:    codes.Unimplemented.String(),
-				Message: fmt.Sprintf("unknown method %s", fullMethod),
-			},
-		},
+// drop abandons the session of conn: its stream is closed, its streaming calls,
+// which cannot continue on another stream, are cancelled, and the calls of the plugin fail.
+func (psc *PluginStreamClient) drop(conn *pluginConn) {
+	conn.sender.fail(errConnectionLost)
+	if conn.cancelStream != nil {
+		conn.cancelStream()
+	}
+	conn.caller.closeCalls(errConnectionLost)
+
+	conn.inflightMu.Lock()
+	defer conn.inflightMu.Unlock()
+	for _, call := range conn.inflight {
+		if !call.unary {
+			call.cancel()
+		}
 	}
-	return psc.stream.Send(msg)
 }
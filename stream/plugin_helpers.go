@@ -34,6 +34,7 @@ import (
 //   - impl: implementation of the gRPC service
 //   - wrapMessage: function to wrap framework message bytes into domain message
 //   - unwrapMessage: function to extract bytes from domain message
+//   - opts: options for the PluginStreamClient (concurrency limits, ...)
 //
 // Example usage:
 //
@@ -60,10 +61,11 @@ func NewPluginStreamClientWithAdapter[T MessageWrapper](
 	impl interface{},
 	wrapMessage func([]byte) T,
 	unwrapMessage func(T) []byte,
+	opts ...Option,
 ) (*PluginStreamClient, error) {
 	// Create adapter
 	adaptedStream := NewBidiStreamAdapter(stream, wrapMessage, unwrapMessage)
 
 	// Create and return client
-	return NewPluginStreamClient(ctx, adaptedStream, pluginName, pluginVersion, service, impl)
+	return NewPluginStreamClient(ctx, adaptedStream, pluginName, pluginVersion, service, impl, opts...)
 }
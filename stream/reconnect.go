@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultMaxReconnectTime is the default time a plugin keeps trying to reconnect.
+const defaultMaxReconnectTime = 5 * time.Minute
+
+// Dialer opens a new stream to the operator. The stream must end when ctx is done.
+type Dialer func(ctx context.Context) (StreamInterface, error)
+
+// ReconnectPolicy describes how a plugin reconnects after losing its stream to the operator.
+type ReconnectPolicy struct {
+	// InitialBackoff is the maximum delay before the first attempt. Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Defaults to 10s.
+	MaxBackoff time.Duration
+
+	// BackoffMultiplier grows the delay after each attempt. Defaults to 2.
+	BackoffMultiplier float64
+
+	// MaxElapsedTime is how long the plugin keeps trying after losing the stream.
+	// Defaults to 5 minutes.
+	MaxElapsedTime time.Duration
+}
+
+// ConnectionState is the state of the stream of a plugin to the operator.
+type ConnectionState int
+
+const (
+	// StateConnected means the plugin is registered and serving calls.
+	StateConnected ConnectionState = iota
+	// StateReconnecting means the stream was lost and the plugin is reconnecting.
+	StateReconnecting
+	// StateDisconnected means the plugin gave up reconnecting; HandleRPCCalls returns.
+	StateDisconnected
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "Connected"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateDisconnected:
+		return "Disconnected"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int(s))
+	}
+}
+
+// ConnectionStateHandler is called when the stream of a plugin changes state.
+// err is the reason of the change, nil when the plugin is connected.
+type ConnectionStateHandler func(state ConnectionState, err error)
+
+// reconnect opens and registers a new stream after the one of prev failed with cause,
+// resuming the session of prev when possible.
+// It retries with exponential backoff until the policy's time is up or ctx is done.
+func (psc *PluginStreamClient) reconnect(ctx context.Context, prev *pluginConn, cause error) (*pluginConn, error) {
+	policy := psc.opts.reconnectPolicy
+	maxElapsed := policy.MaxElapsedTime
+	if maxElapsed <= 0 {
+		maxElapsed = defaultMaxReconnectTime
+	}
+	deadline := time.Now().Add(maxElapsed)
+	logger := log.FromContext(ctx).WithValues("plugin", psc.pluginName)
+
+	lastErr := cause
+	for attempt := 1; ; attempt++ {
+		delay := exponentialBackoff(policy.InitialBackoff, policy.MaxBackoff, policy.BackoffMultiplier, attempt)
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("gave up reconnecting after %v: %w", maxElapsed, lastErr)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		conn, err := psc.dialConn(ctx, prev)
+		if err == nil {
+			logger.Info("Reconnected to the operator", "attempt", attempt, "session", conn.sessionID, "resumed", conn == prev)
+			return conn, nil
+		}
+		logger.Info("Failed to reconnect to the operator", "attempt", attempt, "error", err.Error())
+		lastErr = err
+	}
+}
+
+// dialConn opens a new stream with the dialer and registers the plugin on it,
+// resuming the session of prev when possible.
+func (psc *PluginStreamClient) dialConn(ctx context.Context, prev *pluginConn) (*pluginConn, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := psc.opts.dialer(streamCtx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+
+	conn, err := psc.register(ctx, stream, prev)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	conn.cancelStream = cancel
+	return conn, nil
+}
+
+// setState reports a change of connection state to the handler, if any.
+func (psc *PluginStreamClient) setState(state ConnectionState, err error) {
+	if psc.opts.stateHandler != nil {
+		psc.opts.stateHandler(state, err)
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// ProtocolVersion is the version of the stream protocol implemented by this package.
+// Plugins announcing a version wait for a PluginRegisterAck; legacy plugins announce none.
+const ProtocolVersion = 1
+
+// Optional protocol features, negotiated at registration.
+// A feature is only used on a stream when both sides support it.
+const (
+	// FeatureStreaming enables server-streaming, client-streaming and bidirectional calls.
+	FeatureStreaming = "streaming"
+	// FeaturePayloadChunks enables splitting large payloads in PluginPayloadChunk messages.
+	FeaturePayloadChunks = "payload-chunks"
+	// FeatureCompression enables payload compression.
+	FeatureCompression = "compression"
+	// FeatureServiceUpdates enables advertising services registered after the registration.
+	FeatureServiceUpdates = "service-updates"
+	// FeatureSessionResumption enables resuming a session on a new stream after the
+	// previous one was lost. Plugins support it with WithReconnect, operators with WithSessionStore.
+	FeatureSessionResumption = "session-resumption"
+	// FeatureOperatorServices enables calls of the plugin to the callback services of the
+	// operator (see WithCallbackService and PluginStreamClient.OperatorConn).
+	FeatureOperatorServices = "operator-services"
+	// FeatureNotifications enables notifications of the plugin asking the operator to
+	// reconcile an object (see PluginStreamClient.Notify). Operators support it with
+	// WithNotificationHandler.
+	FeatureNotifications = "notifications"
+)
+
+// features returns the features implemented by this package and enabled by the options.
+func (o *options) features() []string {
+	features := []string{FeatureStreaming, FeaturePayloadChunks, FeatureCompression, FeatureServiceUpdates, FeatureOperatorServices}
+	if o.dialer != nil || o.sessions != nil {
+		features = append(features, FeatureSessionResumption)
+	}
+	return features
+}
+
+// operatorFeatures returns the features supported by an operator with the options.
+// Plugins can always send notifications, operators only accept them with a handler.
+func (o *options) operatorFeatures() []string {
+	features := o.features()
+	if o.notificationHandler != nil {
+		features = append(features, FeatureNotifications)
+	}
+	return features
+}
+
+// pluginFeatures returns the features supported by a plugin with the options.
+func (o *options) pluginFeatures() []string {
+	return append(o.features(), FeatureNotifications)
+}
+
+const (
+	// defaultMaxMessageSize is the default largest message received, the gRPC default.
+	defaultMaxMessageSize = 4 << 20
+
+	// defaultRegisterTimeout is the default time a plugin waits for the registration acknowledgement.
+	defaultRegisterTimeout = 30 * time.Second
+
+	// chunkEnvelopeSize is reserved in a message for the fields around a payload chunk.
+	chunkEnvelopeSize = 1 << 10
+)
+
+// negotiateFeatures returns the features of the plugin that are supported.
+func negotiateFeatures(features, supported []string) []string {
+	var accepted []string
+	for _, feature := range features {
+		if slices.Contains(supported, feature) && !slices.Contains(accepted, feature) {
+			accepted = append(accepted, feature)
+		}
+	}
+	return accepted
+}
+
+// newRegisterMessage builds the registration message of a plugin serving services.
+// The file descriptors of the services are included when o requests it.
+func newRegisterMessage(pluginName, pluginVersion string, services []*grpc.ServiceDesc, o *options) (*pluginframeworkv1.PluginStreamMessage, error) {
+	register := &pluginframeworkv1.PluginRegister{
+		Name:            pluginName,
+		Version:         pluginVersion,
+		Compression:     supportedCompression,
+		ProtocolVersion: ProtocolVersion,
+		Features:        o.pluginFeatures(),
+		Methods:         serviceMethods(services),
+	}
+	if o.fileDescriptors {
+		files, err := serviceFileDescriptors(services)
+		if err != nil {
+			return nil, err
+		}
+		register.FileDescriptors = files
+	}
+
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_Register{
+			Register: register,
+		},
+	}, nil
+}
+
+// newNotificationMessage builds the notification of the plugin asking to reconcile an object.
+func newNotificationMessage(namespace, name, reason string) *pluginframeworkv1.PluginStreamMessage {
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_Notification{
+			Notification: &pluginframeworkv1.PluginNotification{
+				Namespace: namespace,
+				Name:      name,
+				Reason:    reason,
+			},
+		},
+	}
+}
+
+// newServicesUpdateMessage builds the advertisement of services registered after the registration.
+func newServicesUpdateMessage(services []*grpc.ServiceDesc, fileDescriptors bool) (*pluginframeworkv1.PluginStreamMessage, error) {
+	update := &pluginframeworkv1.PluginServicesUpdate{
+		Methods: serviceMethods(services),
+	}
+	if fileDescriptors {
+		files, err := serviceFileDescriptors(services)
+		if err != nil {
+			return nil, err
+		}
+		update.FileDescriptors = files
+	}
+
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_ServicesUpdate{
+			ServicesUpdate: update,
+		},
+	}, nil
+}
+
+// serviceMethods returns the full names of the methods of services.
+func serviceMethods(services []*grpc.ServiceDesc) []string {
+	var methods []string
+	for _, service := range services {
+		for _, m := range service.Methods {
+			methods = append(methods, "/"+service.ServiceName+"/"+m.MethodName)
+		}
+		for _, sd := range service.Streams {
+			methods = append(methods, "/"+service.ServiceName+"/"+sd.StreamName)
+		}
+	}
+	return methods
+}
+
+// serviceFileDescriptors returns the serialized file descriptors declaring services,
+// each followed by its dependencies, as found in the global registry of the protobuf runtime.
+func serviceFileDescriptors(services []*grpc.ServiceDesc) ([][]byte, error) {
+	var files [][]byte
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor) error
+	add = func(fd protoreflect.FileDescriptor) error {
+		if seen[fd.Path()] {
+			return nil
+		}
+		seen[fd.Path()] = true
+
+		b, err := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+		if err != nil {
+			return fmt.Errorf("failed to marshal file descriptor %s: %w", fd.Path(), err)
+		}
+		files = append(files, b)
+
+		imports := fd.Imports()
+		for i := range imports.Len() {
+			if err := add(imports.Get(i).FileDescriptor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, service := range services {
+		filename, ok := service.Metadata.(string)
+		if !ok {
+			return nil, fmt.Errorf("service %s has no file metadata", service.ServiceName)
+		}
+		file, err := protoregistry.GlobalFiles.FindFileByPath(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find file descriptor of service %s: %w", service.ServiceName, err)
+		}
+		if err := add(file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// parseFileDescriptors decodes the file descriptors sent by a plugin.
+func parseFileDescriptors(files [][]byte) ([]*descriptorpb.FileDescriptorProto, error) {
+	fds := make([]*descriptorpb.FileDescriptorProto, 0, len(files))
+	for _, b := range files {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, fd); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid file descriptor: %v", err)
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+// implementsMethod reports whether method is one of the full method names in methods.
+// A method given without its service, as accepted by CallRPC, matches any service.
+func implementsMethod(methods []string, method string) bool {
+	if strings.HasPrefix(method, "/") {
+		return slices.Contains(methods, method)
+	}
+	return slices.ContainsFunc(methods, func(m string) bool {
+		return path.Base(m) == method
+	})
+}
+
+// newRegisterAckMessage wraps ack in a stream message.
+func newRegisterAckMessage(ack *pluginframeworkv1.PluginRegisterAck) *pluginframeworkv1.PluginStreamMessage {
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RegisterAck{
+			RegisterAck: ack,
+		},
+	}
+}
+
+// receiveRegisterAck waits for the operator's answer to the registration.
+// A rejection is returned as an error carrying the operator's status.
+func receiveRegisterAck(ctx context.Context, stream StreamInterface, timeout time.Duration) (*pluginframeworkv1.PluginRegisterAck, error) {
+	type result struct {
+		msg *pluginframeworkv1.PluginStreamMessage
+		err error
+	}
+	received := make(chan result, 1)
+	go func() {
+		msg, err := stream.Recv()
+		received <- result{msg, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var r result
+	select {
+	case r = <-received:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, status.Errorf(codes.DeadlineExceeded, "operator did not acknowledge the registration within %v", timeout)
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to receive registration acknowledgement: %w", r.err)
+	}
+	ack := r.msg.GetRegisterAck()
+	if ack == nil {
+		return nil, fmt.Errorf("first message from the operator must be PluginRegisterAck")
+	}
+
+	if rejection := ack.GetRejection(); rejection != nil {
+		err := status.ErrorProto(rejection)
+		if err == nil {
+			err = status.Error(codes.Unknown, "registration rejected without a reason")
+		}
+		return nil, fmt.Errorf("plugin registration rejected: %w", err)
+	}
+	return ack, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	mathrand "math/rand/v2"
+	"path"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultInitialBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff        = 10 * time.Second
+	defaultBackoffMultiplier = 2.0
+)
+
+// RetryPolicy describes how unary calls to a plugin method are retried by the operator.
+// Every attempt of a call carries the same idempotency key, so that a plugin using
+// WithIdempotencyCache runs the handler only once.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the maximum delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts. Defaults to 10s.
+	MaxBackoff time.Duration
+
+	// BackoffMultiplier grows the delay after each attempt. Defaults to 2.
+	BackoffMultiplier float64
+
+	// RetryableCodes are the status codes worth retrying. When empty, calls are retried
+	// when IsRetryable reports true: UNAVAILABLE, or errors carrying a retry delay.
+	RetryableCodes []codes.Code
+}
+
+// retryable reports whether a call that failed with err may be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	if len(p.RetryableCodes) == 0 {
+		return IsRetryable(err)
+	}
+	return slices.Contains(p.RetryableCodes, status.Code(err))
+}
+
+// backoff returns the delay before the given retry (1 for the first one).
+// As with gRPC retries, the delay is drawn at random up to the exponential backoff.
+// A retry delay suggested by the plugin in err takes precedence.
+func (p *RetryPolicy) backoff(retry int, err error) time.Duration {
+	if delay, ok := RetryDelay(err); ok {
+		return delay
+	}
+
+	return exponentialBackoff(p.InitialBackoff, p.MaxBackoff, p.BackoffMultiplier, retry)
+}
+
+// exponentialBackoff returns a random delay up to the exponential backoff of the given
+// retry (1 for the first one), using the defaults for the unset parameters.
+func exponentialBackoff(initial, maxBackoff time.Duration, multiplier float64, retry int) time.Duration {
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	ceiling := math.Min(float64(initial)*math.Pow(multiplier, float64(retry-1)), float64(maxBackoff))
+	return time.Duration(mathrand.Float64() * ceiling)
+}
+
+// retryPolicy returns the policy configured for method, see lookupRetryPolicy.
+func (c *caller) retryPolicy(method string) *RetryPolicy {
+	return lookupRetryPolicy(c.retryPolicies, method)
+}
+
+// lookupRetryPolicy returns the policy configured for method, by full name first, then by
+// method name, then the default policy. It returns nil if calls are not retried.
+func lookupRetryPolicy(policies map[string]RetryPolicy, method string) *RetryPolicy {
+	for _, name := range []string{method, path.Base(method), ""} {
+		if policy, ok := policies[name]; ok {
+			if policy.MaxAttempts < 2 {
+				return nil
+			}
+			return &policy
+		}
+	}
+	return nil
+}
+
+// retryUnaryInterceptor retries the unary calls of a grpc.ClientConn according to
+// policies, as the calls through a plugin stream are.
+func retryUnaryInterceptor(policies map[string]RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		policy := lookupRetryPolicy(policies, method)
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				return err
+			}
+
+			timer := time.NewTimer(policy.backoff(attempt, err))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+	}
+}
+
+// idempotencyKeyOption is the grpc.CallOption set by IdempotencyKey.
+type idempotencyKeyOption struct {
+	grpc.EmptyCallOption
+	key string
+}
+
+// IdempotencyKey sets the idempotency key of a call through the plugin stream.
+// A plugin using WithIdempotencyCache returns the stored result of a previous call
+// with the same method and key instead of running the handler again.
+// Calls with a retry policy get a random key when none is set.
+func IdempotencyKey(key string) grpc.CallOption {
+	return idempotencyKeyOption{key: key}
+}
+
+// idempotencyKeyFromOptions returns the key set with IdempotencyKey, if any.
+func idempotencyKeyFromOptions(opts []grpc.CallOption) string {
+	var key string
+	for _, opt := range opts {
+		if o, ok := opt.(idempotencyKeyOption); ok {
+			key = o.key
+		}
+	}
+	return key
+}
+
+// randomID returns a random hex identifier, used for idempotency keys and session IDs.
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+const (
+	// defaultSendQueueSize is the default number of outbound messages per lane.
+	defaultSendQueueSize = 256
+
+	// defaultSlowConsumerTimeout is the default time a single write may block.
+	defaultSlowConsumerTimeout = 30 * time.Second
+)
+
+// sender is the single writer of a plugin stream. gRPC streams do not support
+// concurrent Send calls, so every goroutine queues its messages and one goroutine
+// writes them.
+//
+// Messages go through one of two lanes. The control lane carries messages that
+// unblock the peer (flow-control credits, errors) and is always written first.
+// The data lane carries everything else, and must be used for messages whose order
+// matters for a call: a call, its frames and its end, or a response.
+// Messages queued on the same lane by one goroutine are written in order.
+//
+// In a resumable session, the sender numbers and keeps the messages it writes (see
+// sessionLog). A write error then pauses the sender instead of failing it: messages
+// stay queued until attach gives a new stream, on which the messages the peer did not
+// receive are written first.
+type sender struct {
+	stream         StreamInterface
+	stallTimeout   time.Duration
+	maxChunkSize   int
+	maxPayloadSize int
+	compressAbove  int
+
+	control chan *pluginframeworkv1.PluginStreamMessage
+	data    chan *pluginframeworkv1.PluginStreamMessage
+
+	// Resumable session, nil if not resumable
+	session  *sessionLog
+	detachCh chan chan struct{}
+	attachCh chan attachment
+
+	failOnce sync.Once
+	err      error         // set before done is closed
+	done     chan struct{} // closed when the sender fails or is stopped
+	stopped  chan struct{} // closed when the writer goroutine returns
+}
+
+func newSender(stream StreamInterface, o *options) *sender {
+	return &sender{
+		stream:         stream,
+		stallTimeout:   o.slowConsumerTimeout,
+		maxChunkSize:   o.maxChunkSize,
+		maxPayloadSize: o.maxPayloadSize,
+		compressAbove:  o.compressionThreshold,
+		control:        make(chan *pluginframeworkv1.PluginStreamMessage, o.sendQueueSize),
+		data:           make(chan *pluginframeworkv1.PluginStreamMessage, o.sendQueueSize),
+		detachCh:       make(chan chan struct{}),
+		attachCh:       make(chan attachment),
+		done:           make(chan struct{}),
+		stopped:        make(chan struct{}),
+	}
+}
+
+// start runs the writer goroutine.
+func (s *sender) start() {
+	go s.run()
+}
+
+// send queues msg on the data lane, blocking while the lane is full.
+// It fails if ctx is done first or if the sender stopped.
+// The payload is compressed with the algorithm set in the message, if large enough.
+// A large payload is queued as chunks followed by msg; once the first chunk is queued,
+// the others are queued regardless of ctx so that the receiver gets the whole payload.
+// The sender owns msg once it is queued.
+func (s *sender) send(ctx context.Context, msg *pluginframeworkv1.PluginStreamMessage) error {
+	if err := compressPayload(msg, s.compressAbove); err != nil {
+		return err
+	}
+	for i, m := range splitPayload(msg, s.maxChunkSize) {
+		if i == 1 {
+			ctx = context.Background()
+		}
+		if err := s.enqueue(ctx, s.data, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendControl queues msg on the control lane, blocking while the lane is full.
+func (s *sender) sendControl(msg *pluginframeworkv1.PluginStreamMessage) error {
+	return s.enqueue(context.Background(), s.control, msg)
+}
+
+func (s *sender) enqueue(ctx context.Context, lane chan *pluginframeworkv1.PluginStreamMessage, msg *pluginframeworkv1.PluginStreamMessage) error {
+	// Fail fast rather than racing a stopped sender for queue space
+	select {
+	case <-s.done:
+		return s.err
+	default:
+	}
+
+	select {
+	case lane <- msg:
+		return nil
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// attachment is a new stream for a paused sender, with the messages to write first.
+type attachment struct {
+	stream StreamInterface
+	replay []*pluginframeworkv1.PluginStreamMessage
+}
+
+// run writes queued messages until the sender fails or is stopped.
+// A write blocking longer than stallTimeout fails the sender with ErrSlowConsumer:
+// the peer stopped reading, and the stream must be torn down to unblock the write.
+func (s *sender) run() {
+	defer close(s.stopped)
+
+	stall := time.AfterFunc(s.stallTimeout, func() { s.fail(ErrSlowConsumer) })
+	stall.Stop()
+
+	stream := s.stream
+	var replay []*pluginframeworkv1.PluginStreamMessage
+	for {
+		// Paused, until a new stream is attached
+		if stream == nil {
+			select {
+			case a := <-s.attachCh:
+				stream, replay = a.stream, a.replay
+			case detached := <-s.detachCh:
+				close(detached)
+			case <-s.done:
+				return
+			}
+			continue
+		}
+
+		var msg *pluginframeworkv1.PluginStreamMessage
+		if len(replay) > 0 {
+			msg, replay = replay[0], replay[1:]
+		} else {
+			select {
+			case msg = <-s.control:
+			default:
+				select {
+				case msg = <-s.control:
+				case msg = <-s.data:
+				case detached := <-s.detachCh:
+					stream, replay = nil, nil
+					close(detached)
+					continue
+				case <-s.done:
+					return
+				}
+			}
+			if s.session != nil {
+				s.session.record(msg)
+			}
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		stall.Reset(s.stallTimeout)
+		err := stream.Send(msg)
+		stall.Stop()
+		if err != nil {
+			if s.session != nil {
+				// The message is kept in the session, and written again on the next stream
+				stream, replay = nil, nil
+				continue
+			}
+			s.fail(fmt.Errorf("failed to send message: %w", err))
+			return
+		}
+	}
+}
+
+// detach makes the writer leave its stream, which was lost, and waits for it to pause,
+// at most for the slow-consumer timeout. Messages are then queued until attach.
+// It reports whether the sender paused; it is failed otherwise.
+func (s *sender) detach() bool {
+	timer := time.NewTimer(s.stallTimeout)
+	defer timer.Stop()
+
+	detached := make(chan struct{})
+	select {
+	case s.detachCh <- detached:
+	case <-s.done:
+		return false
+	case <-timer.C:
+		s.fail(ErrSlowConsumer)
+		return false
+	}
+
+	select {
+	case <-detached:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// attach resumes a paused sender on stream, writing replay before the queued messages.
+// It reports false if the sender failed.
+func (s *sender) attach(stream StreamInterface, replay []*pluginframeworkv1.PluginStreamMessage) bool {
+	select {
+	case s.attachCh <- attachment{stream: stream, replay: replay}:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// fail stops the sender with err. Queued messages are dropped and later sends return err.
+// Only the first error is kept.
+func (s *sender) fail(err error) {
+	s.failOnce.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+// stop fails the sender with err and waits for the writer goroutine to return,
+// at most for the slow-consumer timeout. It reports whether the writer returned,
+// after which the stream may be written to directly again.
+func (s *sender) stop(err error) bool {
+	s.fail(err)
+
+	timer := time.NewTimer(s.stallTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-s.stopped:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// receive runs stream.Recv on its own goroutine, so that the receive loops can also
+// watch their context and the sender. Messages are delivered on the first channel
+// until stop is closed; the receive error, if any, on the second.
+func receive(stream StreamInterface, stop <-chan struct{}) (<-chan *pluginframeworkv1.PluginStreamMessage, <-chan error) {
+	msgs := make(chan *pluginframeworkv1.PluginStreamMessage)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case msgs <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return msgs, errs
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+// serverStream is the handling side of a streaming RPC call.
+// It implements grpc.ServerStream on top of the plugin stream.
+type serverStream struct {
+	ctx           context.Context
+	sts           *serverTransportStream
+	call          *inflightCall
+	requestID     string
+	clientStreams bool
+
+	// request is the single request of a server-streaming call
+	request  []byte
+	received bool
+}
+
+var _ grpc.ServerStream = (*serverStream)(nil)
+
+func (ss *serverStream) SetHeader(md metadata.MD) error {
+	return ss.sts.SetHeader(md)
+}
+
+func (ss *serverStream) SendHeader(md metadata.MD) error {
+	return ss.sts.SendHeader(md)
+}
+
+func (ss *serverStream) SetTrailer(md metadata.MD) {
+	_ = ss.sts.SetTrailer(md)
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// SendMsg sends a stream message to the caller, preceded by the header on first use.
+// It blocks while the caller has not read the previous messages.
+func (ss *serverStream) SendMsg(m any) error {
+	out, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "message type %T is not a proto.Message", m)
+	}
+
+	if err := ss.sts.flushHeader(); err != nil {
+		return err
+	}
+
+	payload, err := proto.Marshal(out)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to marshal stream message: %v", err)
+	}
+	if err := checkPayloadSize(len(payload), ss.call.conn.sender.maxPayloadSize); err != nil {
+		return err
+	}
+
+	if !ss.call.window.acquire(ss.ctx.Done()) {
+		return status.FromContextError(ss.ctx.Err()).Err()
+	}
+
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_StreamFrame{
+			StreamFrame: &pluginframeworkv1.PluginStreamFrame{
+				RequestId:   ss.requestID,
+				Payload:     payload,
+				Compression: ss.call.compression,
+			},
+		},
+	}
+	return ss.call.conn.sender.send(ss.ctx, msg)
+}
+
+// RecvMsg receives the next request message. It returns io.EOF once the caller
+// half-closed the call, or, for server-streaming calls, after the single request.
+func (ss *serverStream) RecvMsg(m any) error {
+	in, ok := m.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "message type %T is not a proto.Message", m)
+	}
+
+	var payload []byte
+	if ss.clientStreams {
+		var grant int
+		payload, grant, ok = ss.call.recv.pop(ss.ctx.Done())
+		if !ok {
+			if err := ss.ctx.Err(); err != nil {
+				return status.FromContextError(err).Err()
+			}
+			return io.EOF
+		}
+		if grant > 0 {
+			if err := ss.call.conn.sender.sendControl(newWindowUpdateMessage(ss.requestID, grant)); err != nil {
+				return err
+			}
+		}
+	} else {
+		if ss.received {
+			return io.EOF
+		}
+		ss.received = true
+		payload = ss.request
+	}
+
+	if err := proto.Unmarshal(payload, in); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmarshal request: %v", err)
+	}
+	return nil
+}
+
+// handleStreamCall runs the handler of a streaming method and sends its final status.
+func (d *dispatcher) handleStreamCall(ctx context.Context, rpcCall *pluginframeworkv1.PluginRPCCall, call *inflightCall, impl any, desc *grpc.StreamDesc, sts *serverTransportStream) error {
+	requestID := rpcCall.GetRequestId()
+
+	sts.sendHeader = func(md metadata.MD) error {
+		return call.conn.sender.send(context.Background(), &pluginframeworkv1.PluginStreamMessage{
+			Payload: &pluginframeworkv1.PluginStreamMessage_StreamHeader{
+				StreamHeader: &pluginframeworkv1.PluginStreamHeader{
+					RequestId: requestID,
+					Header:    metadataToProto(md),
+				},
+			},
+		})
+	}
+
+	ss := &serverStream{
+		ctx:           ctx,
+		sts:           sts,
+		call:          call,
+		requestID:     requestID,
+		clientStreams: desc.ClientStreams,
+		request:       rpcCall.GetPayload(),
+	}
+
+	st := status.New(codes.OK, "")
+	info := &grpc.StreamServerInfo{
+		FullMethod:     sts.method,
+		IsClientStream: desc.ClientStreams,
+		IsServerStream: desc.ServerStreams,
+	}
+	if err := d.streamInterceptor(impl, ss, info, desc.Handler); err != nil {
+		st = handlerStatus(err)
+	}
+
+	_, trailer := sts.collected()
+	msg := &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_StreamEnd{
+			StreamEnd: &pluginframeworkv1.PluginStreamEnd{
+				RequestId: requestID,
+				Status:    st.Proto(),
+				Trailer:   metadataToProto(trailer),
+			},
+		},
+	}
+	// The final status is sent even if the handler context was cancelled,
+	// for instance when the caller overran the flow-control window
+	return call.conn.sender.send(context.Background(), msg)
+}
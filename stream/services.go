@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// serviceInfo is a service served on a stream, with its handlers by method name.
+type serviceInfo struct {
+	desc    *grpc.ServiceDesc
+	impl    any
+	methods map[string]*grpc.MethodDesc
+	streams map[string]*grpc.StreamDesc
+}
+
+// serviceSet holds the services served on a stream, by full service name.
+type serviceSet struct {
+	mu       sync.RWMutex
+	order    []*serviceInfo // by order of registration, for method names without service
+	services map[string]*serviceInfo
+}
+
+func newServiceSet() *serviceSet {
+	return &serviceSet{services: make(map[string]*serviceInfo)}
+}
+
+// add registers a service implemented by impl.
+// As with grpc.Server, impl must implement the handler type of the service.
+func (s *serviceSet) add(desc *grpc.ServiceDesc, impl any) error {
+	if impl != nil && desc.HandlerType != nil {
+		ht := reflect.TypeOf(desc.HandlerType).Elem()
+		if st := reflect.TypeOf(impl); !st.Implements(ht) {
+			return fmt.Errorf("handler of type %v does not satisfy %v", st, ht)
+		}
+	}
+
+	info := &serviceInfo{
+		desc:    desc,
+		impl:    impl,
+		methods: make(map[string]*grpc.MethodDesc, len(desc.Methods)),
+		streams: make(map[string]*grpc.StreamDesc, len(desc.Streams)),
+	}
+	for i := range desc.Methods {
+		info.methods[desc.Methods[i].MethodName] = &desc.Methods[i]
+	}
+	for i := range desc.Streams {
+		info.streams[desc.Streams[i].StreamName] = &desc.Streams[i]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.services[desc.ServiceName]; exists {
+		return fmt.Errorf("duplicate service registration for %q", desc.ServiceName)
+	}
+	s.services[desc.ServiceName] = info
+	s.order = append(s.order, info)
+	return nil
+}
+
+// lookup returns the service serving fullMethod ("/package.Service/Method"), and the
+// method name. A method given without its service, as accepted by CallRPC, is looked up
+// in every service, by order of registration. It returns nil if no service serves it.
+func (s *serviceSet) lookup(fullMethod string) (*serviceInfo, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	method := path.Base(fullMethod)
+	if strings.HasPrefix(fullMethod, "/") {
+		info := s.services[path.Dir(fullMethod[1:])]
+		if info == nil || !info.serves(method) {
+			return nil, method
+		}
+		return info, method
+	}
+
+	for _, info := range s.order {
+		if info.serves(method) {
+			return info, method
+		}
+	}
+	return nil, method
+}
+
+// descs returns the descriptors of the services, by order of registration.
+func (s *serviceSet) descs() []*grpc.ServiceDesc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	descs := make([]*grpc.ServiceDesc, 0, len(s.order))
+	for _, info := range s.order {
+		descs = append(descs, info.desc)
+	}
+	return descs
+}
+
+// serves reports whether the service has a method or stream named method.
+func (info *serviceInfo) serves(method string) bool {
+	return info.methods[method] != nil || info.streams[method] != nil
+}
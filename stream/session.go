@@ -0,0 +1,200 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+)
+
+const (
+	// DefaultResumeGracePeriod is the grace period of a SessionStore created with no duration.
+	DefaultResumeGracePeriod = 30 * time.Second
+
+	// resumeBufferSize bounds the size of the messages kept for replay in a session.
+	// Older messages are dropped beyond it, and a session missing them cannot be resumed.
+	resumeBufferSize = 16 << 20
+
+	// ackEvery and ackInterval set how often received messages are acknowledged:
+	// after ackEvery messages, or ackInterval after the last acknowledgement.
+	ackEvery    = 32
+	ackInterval = 200 * time.Millisecond
+)
+
+// sessionLog numbers the messages sent in one direction of a resumable session, keeps them
+// until the peer acknowledges them, and tracks the messages received from the peer.
+type sessionLog struct {
+	mu sync.Mutex
+
+	// Messages sent and not acknowledged yet, in order
+	lastSent    uint64
+	unacked     []*pluginframeworkv1.PluginStreamMessage
+	unackedSize int
+	dropped     uint64 // sequence of the last message dropped without acknowledgement
+
+	// Messages received from the peer
+	received uint64
+	acked    uint64 // last sequence acknowledged to the peer
+}
+
+// record numbers msg, the next message written to the stream, and keeps it for replay.
+// Session acknowledgements are neither numbered nor kept.
+func (l *sessionLog) record(msg *pluginframeworkv1.PluginStreamMessage) {
+	if msg.GetSessionAck() != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastSent++
+	msg.Sequence = l.lastSent
+	l.unacked = append(l.unacked, msg)
+	l.unackedSize += proto.Size(msg)
+
+	for l.unackedSize > resumeBufferSize && len(l.unacked) > 1 {
+		l.unackedSize -= proto.Size(l.unacked[0])
+		l.dropped = l.unacked[0].GetSequence()
+		l.unacked[0] = nil
+		l.unacked = l.unacked[1:]
+	}
+}
+
+// acknowledge forgets the messages the peer received, up to sequence.
+func (l *sessionLog) acknowledge(sequence uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for len(l.unacked) > 0 && l.unacked[0].GetSequence() <= sequence {
+		l.unackedSize -= proto.Size(l.unacked[0])
+		l.unacked[0] = nil
+		l.unacked = l.unacked[1:]
+	}
+}
+
+// replay returns the messages to send again to a peer that received them up to sequence.
+// It fails when some of them were dropped, or when the peer received messages never sent.
+func (l *sessionLog) replay(sequence uint64) ([]*pluginframeworkv1.PluginStreamMessage, error) {
+	l.acknowledge(sequence)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sequence > l.lastSent {
+		return nil, fmt.Errorf("peer received message %d, only %d were sent", sequence, l.lastSent)
+	}
+	if sequence < l.dropped {
+		return nil, fmt.Errorf("message %d is no longer available for replay", sequence+1)
+	}
+	return append([]*pluginframeworkv1.PluginStreamMessage(nil), l.unacked...), nil
+}
+
+// receive records the sequence of a message received from the peer.
+// It reports false for a message already received, which must be ignored,
+// and whether the received messages should be acknowledged now.
+func (l *sessionLog) receive(msg *pluginframeworkv1.PluginStreamMessage) (fresh bool, ackDue bool) {
+	sequence := msg.GetSequence()
+	if sequence == 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sequence <= l.received {
+		return false, false
+	}
+	l.received = sequence
+	return true, l.received-l.acked >= ackEvery
+}
+
+// lastReceived returns the sequence of the last message received from the peer.
+func (l *sessionLog) lastReceived() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.received
+}
+
+// ackMessage returns the acknowledgement of the messages received since the last one,
+// or nil if there are none.
+func (l *sessionLog) ackMessage() *pluginframeworkv1.PluginStreamMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.received == l.acked {
+		return nil
+	}
+	l.acked = l.received
+	return &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_SessionAck{
+			SessionAck: &pluginframeworkv1.PluginSessionAck{Sequence: l.acked},
+		},
+	}
+}
+
+// SessionStore keeps the resumable sessions of the plugins connected to an operator.
+// A plugin losing its stream can resume its session on a new stream within the grace
+// period: calls in flight on both sides then complete as if the stream was never lost.
+// Share one store between the StreamManagers of an operator with WithSessionStore.
+type SessionStore struct {
+	gracePeriod time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*StreamManager // by resume token
+}
+
+// NewSessionStore creates a SessionStore keeping sessions for gracePeriod after their
+// stream is lost, DefaultResumeGracePeriod if zero.
+func NewSessionStore(gracePeriod time.Duration) *SessionStore {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultResumeGracePeriod
+	}
+	return &SessionStore{
+		gracePeriod: gracePeriod,
+		sessions:    make(map[string]*StreamManager),
+	}
+}
+
+// add makes the session of sm resumable with token.
+func (s *SessionStore) add(token string, sm *StreamManager) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[token] = sm
+}
+
+// get returns the session of token, or nil.
+func (s *SessionStore) get(token string) *StreamManager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[token]
+}
+
+// remove forgets the session of token.
+func (s *SessionStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+// Handshake environment of the plugin executables launched by an operator
+// (see server.WithSubprocessPlugins and client.NewSubprocess).
+const (
+	// SubprocessCookieEnv is set to SubprocessCookie in the environment of a launched
+	// plugin. It is not a security measure: it only tells a plugin executable that it was
+	// launched by an operator, rather than run by hand.
+	SubprocessCookieEnv = "PLUGIN_FRAMEWORK_COOKIE"
+	SubprocessCookie    = "2f1d7c43b9a84e6f8e0a5b1c9d3f7a26"
+
+	// SubprocessAddrEnv is the address of the private socket the plugin connects to.
+	SubprocessAddrEnv = "PLUGIN_FRAMEWORK_ADDR"
+
+	// SubprocessNameEnv is the name the plugin must register with.
+	SubprocessNameEnv = "PLUGIN_FRAMEWORK_NAME"
+
+	// SubprocessTokenEnv is the one-time token authenticating the stream of the plugin.
+	// A new token is given each time the plugin is launched.
+	SubprocessTokenEnv = "PLUGIN_FRAMEWORK_TOKEN"
+)
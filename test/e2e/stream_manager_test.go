@@ -2,13 +2,48 @@ package e2e
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr/funcr"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pluginframeworkv1alpha1 "github.com/guilhem/operator-plugin-framework/api/v1alpha1"
+	"github.com/guilhem/operator-plugin-framework/client"
+	"github.com/guilhem/operator-plugin-framework/registration"
 	"github.com/guilhem/operator-plugin-framework/server"
+	"github.com/guilhem/operator-plugin-framework/stream"
 )
 
 // TestStreamManagerBasicRegistration tests basic plugin registration and unregistration
@@ -179,12 +214,19 @@ func TestStreamManagerMaxConnections(t *testing.T) {
 	}
 
 	// Try to register one more (should fail)
-	pluginCtx, _ := context.WithCancel(t.Context())
+	pluginCtx, pluginCancel := context.WithCancel(t.Context())
+	defer pluginCancel()
 	err := sm.HandlePluginStream(pluginCtx, "extra-plugin")
 	if err != server.ErrMaxConnectionsReached {
 		t.Errorf("expected ErrMaxConnectionsReached, got %v", err)
 	}
 
+	// Replicas of a connected plugin count as connections too
+	err = sm.HandlePluginStream(pluginCtx, "plugin-0")
+	if err != server.ErrMaxConnectionsReached {
+		t.Errorf("expected ErrMaxConnectionsReached for a replica, got %v", err)
+	}
+
 	// Cleanup
 	for _, cancel := range contexts {
 		if cancel != nil {
@@ -339,3 +381,916 @@ func (h *testConnectionHandler) OnPluginDisconnect(name string) error {
 	}
 	return nil
 }
+
+// startServer starts a plugin server on a temporary socket and returns its address.
+func startServer(t *testing.T, opts ...server.ServerOption) (*server.Server, string) {
+	t.Helper()
+
+	addr := fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock"))
+	s := server.New(addr, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-errs
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	return s, addr
+}
+
+// TestServerRegistrationAck tests that an accepted plugin gets a session and serves calls
+func TestServerRegistrationAck(t *testing.T) {
+	s, addr := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := client.New(ctx, "ack-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	go func() { _ = c.HandleRPCCalls(ctx) }()
+
+	if c.GetSessionID() == "" {
+		t.Error("expected a session ID")
+	}
+	if !slices.Contains(c.GetFeatures(), stream.FeatureStreaming) {
+		t.Errorf("expected streaming to be accepted, got %v", c.GetFeatures())
+	}
+
+	conn, err := s.PluginConn("ack-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	callCtx := metadata.AppendToOutgoingContext(ctx, "x-request", "ack", "x-user", "operator")
+	resp, err := grpc_testing.NewTestServiceClient(conn).UnaryCall(callCtx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "operator" {
+		t.Errorf("expected username operator, got %q", resp.GetUsername())
+	}
+
+	if got := s.GetRegistry().FindByService("grpc.testing.TestService"); !slices.Equal(got, []string{"ack-plugin"}) {
+		t.Errorf("expected ack-plugin to serve TestService, got %v", got)
+	}
+	if got := s.GetRegistry().FindByMethod(grpc_testing.UnimplementedService_UnimplementedCall_FullMethodName); len(got) != 0 {
+		t.Errorf("expected no plugin to serve UnimplementedCall, got %v", got)
+	}
+
+	if _, err := s.PluginConn("unknown-plugin"); !errors.Is(err, server.ErrPluginNotFound) {
+		t.Errorf("expected ErrPluginNotFound, got %v", err)
+	}
+}
+
+// TestServerRejectsPluginAtMaxConnections tests that a plugin over the connection limit
+// fails to connect with the reason of the rejection
+func TestServerRejectsPluginAtMaxConnections(t *testing.T) {
+	s, addr := startServer(t, server.WithMaxConnections(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first, err := client.New(ctx, "first-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = first.Close() }()
+
+	second, err := client.New(ctx, "second-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+	if err == nil {
+		_ = second.Close()
+		t.Fatal("expected the second plugin to be rejected")
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+	if !strings.Contains(err.Error(), server.ErrMaxConnectionsReached.Error()) {
+		t.Errorf("expected the rejection reason in %q", err)
+	}
+
+	if s.IsPluginConnected("second-plugin") {
+		t.Error("rejected plugin should not be registered")
+	}
+	if s.ConnectionCount() != 1 {
+		t.Errorf("expected 1 connection, got %d", s.ConnectionCount())
+	}
+}
+
+// TestServerServiceRegistrar tests that services registered with generated
+// Register*Server functions after the connection are advertised and served
+func TestServerServiceRegistrar(t *testing.T) {
+	s, addr := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := client.New(ctx, "registrar-plugin", addr, "v1.0.0", grpc.ServiceDesc{}, nil)
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	go func() { _ = c.HandleRPCCalls(ctx) }()
+
+	grpc_testing.RegisterBenchmarkServiceServer(c, &benchmarkTestService{})
+
+	// The advertisement is sent after the registration
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.GetRegistry().FindByService("grpc.testing.BenchmarkService")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("BenchmarkService was not advertised")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.AdvertiseError(); err != nil {
+		t.Errorf("AdvertiseError() = %v", err)
+	}
+
+	conn, err := s.PluginConn("registrar-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	resp, err := grpc_testing.NewBenchmarkServiceClient(conn).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "benchmark" {
+		t.Errorf("expected BenchmarkService to answer, got %q", resp.GetUsername())
+	}
+
+	// Services not advertised are rejected by the operator
+	_, err = grpc_testing.NewTestServiceClient(conn).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented for TestService, got %v", err)
+	}
+
+	// An implementation of another service is caught at registration
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected RegisterService to panic on a mismatched implementation")
+		}
+	}()
+	c.RegisterService(&grpc_testing.TestService_ServiceDesc, &benchmarkTestService{})
+}
+
+// TestServerCallbackServices tests that plugins call the services registered on the server
+func TestServerCallbackServices(t *testing.T) {
+	addr := fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock"))
+	s := server.New(addr)
+	grpc_testing.RegisterBenchmarkServiceServer(s, &callbackTestService{})
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("expected RegisterService to panic on a duplicate service")
+			}
+		}()
+		grpc_testing.RegisterBenchmarkServiceServer(s, &callbackTestService{})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-errs
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	c, err := client.New(ctx, "reporting-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	go func() { _ = c.HandleRPCCalls(ctx) }()
+
+	resp, err := grpc_testing.NewBenchmarkServiceClient(c.OperatorConn()).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "reporting-plugin" {
+		t.Errorf("expected the server to identify reporting-plugin, got %q", resp.GetUsername())
+	}
+}
+
+// replicaTestService answers with the name of its replica.
+type replicaTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	name string
+}
+
+func (s *replicaTestService) UnaryCall(context.Context, *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return &grpc_testing.SimpleResponse{Username: s.name}, nil
+}
+
+// TestServerReplicaTakesOver tests that an older replica of a plugin keeps serving it
+// when the newest one disconnects first
+func TestServerReplicaTakesOver(t *testing.T) {
+	s, addr := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connect := func(name, version string) *client.Client {
+		t.Helper()
+		c, err := client.New(ctx, "replicated-plugin", addr, version, grpc_testing.TestService_ServiceDesc, &replicaTestService{name: name})
+		if err != nil {
+			t.Fatalf("client.New() error = %v", err)
+		}
+		go func() { _ = c.HandleRPCCalls(ctx) }()
+		return c
+	}
+	waitReplicas := func(replicas int) {
+		t.Helper()
+		for {
+			info := s.GetStreamManager().GetPluginInfo("replicated-plugin")
+			if (replicas == 0 && info == nil) || (info != nil && info.Replicas == replicas) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected %d replicas, got %+v", replicas, info)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	older := connect("older", "v1.0.0")
+	defer func() { _ = older.Close() }()
+	waitReplicas(1)
+	newer := connect("newer", "v2.0.0")
+	waitReplicas(2)
+
+	_ = newer.Close()
+	waitReplicas(1)
+	if s.ConnectionCount() != 1 || !s.IsPluginConnected("replicated-plugin") {
+		t.Fatalf("expected the older replica to stay connected, got %d connections", s.ConnectionCount())
+	}
+	if info := s.GetStreamManager().GetPluginInfo("replicated-plugin"); info.Version != "v1.0.0" {
+		t.Errorf("expected the version of the older replica, got %q", info.Version)
+	}
+
+	// Calls reach the older replica
+	conn, err := s.PluginConn("replicated-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	resp, err := grpc_testing.NewTestServiceClient(conn).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "older" {
+		t.Errorf("expected the older replica to answer, got %q", resp.GetUsername())
+	}
+
+	// The plugin is still reported connected
+	scheme := runtime.NewScheme()
+	if err := pluginframeworkv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&pluginframeworkv1alpha1.PluginRegistration{}).Build()
+	key := types.NamespacedName{Namespace: "operator-system", Name: "replicated-plugin"}
+	if _, err := registration.New(c, s, key.Namespace).Reconcile(ctx, reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	reg := &pluginframeworkv1alpha1.PluginRegistration{}
+	if err := c.Get(ctx, key, reg); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reg.Status.Health != pluginframeworkv1alpha1.PluginHealthy || reg.Status.Replicas != 1 {
+		t.Errorf("expected a connected plugin with 1 replica, got %s with %d", reg.Status.Health, reg.Status.Replicas)
+	}
+
+	_ = older.Close()
+	waitReplicas(0)
+	if s.IsPluginConnected("replicated-plugin") {
+		t.Error("expected the plugin to be disconnected with its last replica")
+	}
+}
+
+// TestServerPluginEventSource tests that the event source reports plugins connecting,
+// changing version and disconnecting
+func TestServerPluginEventSource(t *testing.T) {
+	s, addr := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	connect := func(version string) *client.Client {
+		t.Helper()
+		c, err := client.New(ctx, "events-plugin", addr, version, grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+		if err != nil {
+			t.Fatalf("client.New() error = %v", err)
+		}
+		go func() { _ = c.HandleRPCCalls(ctx) }()
+		return c
+	}
+
+	// A plugin connected before the source starts is reported first
+	first := connect("v1.0.0")
+	defer func() { _ = first.Close() }()
+
+	events := make(chan server.PluginEvent, 10)
+	h := handler.TypedFuncs[server.PluginEvent, reconcile.Request]{
+		GenericFunc: func(_ context.Context, e event.TypedGenericEvent[server.PluginEvent], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			events <- e.Object
+		},
+	}
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := s.Source(h).Start(ctx, queue); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	expect := func(want server.PluginEvent) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("expected event %+v, got %+v", want, got)
+			}
+		case <-ctx.Done():
+			t.Fatalf("expected event %+v, got none", want)
+		}
+	}
+
+	expect(server.PluginEvent{Type: server.PluginConnected, Plugin: "events-plugin", Version: "v1.0.0"})
+
+	// A new replica with another version replaces the first stream
+	second := connect("v2.0.0")
+	defer func() { _ = second.Close() }()
+	expect(server.PluginEvent{Type: server.PluginVersionChanged, Plugin: "events-plugin", Version: "v2.0.0", PreviousVersion: "v1.0.0"})
+
+	if info := s.GetStreamManager().GetPluginInfo("events-plugin"); info == nil || info.Version != "v2.0.0" {
+		t.Errorf("expected plugin info of version v2.0.0, got %+v", info)
+	}
+
+	// The plugin is disconnected once its last stream is closed
+	_ = first.Close()
+	_ = second.Close()
+	expect(server.PluginEvent{Type: server.PluginDisconnected, Plugin: "events-plugin"})
+
+	select {
+	case evt := <-events:
+		t.Errorf("expected no more events, got %+v", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestServerPluginEventSourceMergesPendingEvents tests that the events of a plugin pending
+// while the handler is busy are merged into the latest one
+func TestServerPluginEventSourceMergesPendingEvents(t *testing.T) {
+	s, addr := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events := make(chan server.PluginEvent, 10)
+	release := make(chan struct{})
+	h := handler.TypedFuncs[server.PluginEvent, reconcile.Request]{
+		GenericFunc: func(_ context.Context, e event.TypedGenericEvent[server.PluginEvent], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			events <- e.Object
+			<-release
+		},
+	}
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := s.Source(h).Start(ctx, queue); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	waitConnected := func(connected bool) {
+		t.Helper()
+		for s.IsPluginConnected("flapping-plugin") != connected {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected flapping-plugin connected to be %v", connected)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	// The handler is kept busy with the first connection while the plugin reconnects
+	for i := range 3 {
+		c, err := client.New(ctx, "flapping-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+		if err != nil {
+			t.Fatalf("client.New() error = %v", err)
+		}
+		go func() { _ = c.HandleRPCCalls(ctx) }()
+		waitConnected(true)
+		if i == 0 {
+			<-events
+		}
+		_ = c.Close()
+		waitConnected(false)
+	}
+	close(release)
+
+	select {
+	case got := <-events:
+		if got.Type != server.PluginDisconnected {
+			t.Errorf("expected the pending events to be merged into a disconnection, got %+v", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a pending event, got none")
+	}
+	select {
+	case evt := <-events:
+		t.Errorf("expected no more events, got %+v", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestServerNotificationSource tests that plugin notifications enqueue reconcile requests,
+// within the rate limit of the plugin and when authorized
+func TestServerNotificationSource(t *testing.T) {
+	authorizer := func(_ context.Context, n server.PluginNotification) error {
+		if n.Key.Namespace != "default" {
+			return fmt.Errorf("plugin %s may not reconcile objects in %s", n.Plugin, n.Key.Namespace)
+		}
+		return nil
+	}
+	s, addr := startServer(t, server.WithNotificationRateLimit(rate.Every(time.Hour), 2), server.WithNotificationAuthorizer(authorizer))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	if err := s.NotificationSource().Start(ctx, queue); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	c, err := client.New(ctx, "notifying-plugin", addr, "v1.0.0", grpc_testing.TestService_ServiceDesc, &metadataTestService{})
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	go func() { _ = c.HandleRPCCalls(ctx) }()
+
+	if !slices.Contains(c.GetFeatures(), stream.FeatureNotifications) {
+		t.Fatalf("expected notifications to be accepted, got %v", c.GetFeatures())
+	}
+	if err := c.Notify(ctx, "default", "", "deleted"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a notification without object, got %v", err)
+	}
+
+	// The second notification is refused, the third is over the rate limit
+	for _, key := range []types.NamespacedName{
+		{Namespace: "default", Name: "bucket"},
+		{Namespace: "kube-system", Name: "secret"},
+		{Namespace: "default", Name: "other-bucket"},
+	} {
+		if err := c.Notify(ctx, key.Namespace, key.Name, "deleted out-of-band"); err != nil {
+			t.Fatalf("Notify(%s) error = %v", key, err)
+		}
+	}
+
+	req, shutdown := queue.Get()
+	if shutdown {
+		t.Fatal("queue shut down")
+	}
+	queue.Done(req)
+	if want := (reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "bucket"}}); req != want {
+		t.Errorf("expected request %v, got %v", want, req)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if n := queue.Len(); n != 0 {
+		t.Errorf("expected no more requests, got %d", n)
+	}
+}
+
+// TestServerDialsPluginEndpoints tests that a plugin served as a plain gRPC server is
+// dialed by the server, and called like a plugin connecting to it
+func TestServerDialsPluginEndpoints(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	pluginServer := grpc.NewServer()
+	grpc_testing.RegisterTestServiceServer(pluginServer, &metadataTestService{})
+	reflection.Register(pluginServer)
+	go func() { _ = pluginServer.Serve(lis) }()
+	defer pluginServer.Stop()
+
+	var intercepted atomic.Int32
+	countCalls := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		intercepted.Add(1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	s, _ := startServer(t,
+		server.WithPluginEndpoints(server.PluginEndpoint{
+			Name:    "dialed-plugin",
+			Version: "v1.0.0",
+			Target:  "unix://" + socket,
+		}),
+		server.WithStreamOptions(stream.WithUnaryClientInterceptors(countCalls)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	waitConnected := func(connected bool) {
+		t.Helper()
+		for s.IsPluginConnected("dialed-plugin") != connected {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected dialed-plugin connected to be %v", connected)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	waitConnected(true)
+
+	conn, err := s.PluginConn("dialed-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	callCtx := metadata.AppendToOutgoingContext(ctx, "x-request", "dial", "x-user", "operator")
+	resp, err := grpc_testing.NewTestServiceClient(conn).UnaryCall(callCtx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "operator" {
+		t.Errorf("expected username operator, got %q", resp.GetUsername())
+	}
+	if n := intercepted.Load(); n != 1 {
+		t.Errorf("expected the call to go through the client interceptor once, got %d", n)
+	}
+
+	if got := s.GetRegistry().FindByService("grpc.testing.TestService"); !slices.Equal(got, []string{"dialed-plugin"}) {
+		t.Errorf("expected dialed-plugin to serve TestService, got %v", got)
+	}
+	if info := s.GetStreamManager().GetPluginInfo("dialed-plugin"); info == nil || info.Version != "v1.0.0" {
+		t.Errorf("expected plugin info of version v1.0.0, got %+v", info)
+	}
+
+	// The plugin is released when it goes away
+	pluginServer.Stop()
+	waitConnected(false)
+	if _, err := s.GetRegistry().Get("dialed-plugin"); err == nil {
+		t.Error("expected dialed-plugin to be unregistered")
+	}
+}
+
+// discoveryResolver resolves the "dns" targets of discovered Services to a test plugin,
+// and records them.
+type discoveryResolver struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (r *discoveryResolver) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	r.mu.Lock()
+	r.targets = append(r.targets, target.URL.String())
+	r.mu.Unlock()
+
+	return r, cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: "plugin"}}})
+}
+
+func (r *discoveryResolver) Scheme() string { return "dns" }
+
+func (r *discoveryResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *discoveryResolver) Close() {}
+
+func (r *discoveryResolver) resolved() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A target is resolved again when its connection is rebuilt
+	return slices.Compact(slices.Sorted(slices.Values(r.targets)))
+}
+
+// dialOptions returns the options of connections resolved by r to the plugin served on socket.
+func (r *discoveryResolver) dialOptions(socket string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithResolvers(r),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}),
+	}
+}
+
+// TestServerServiceDiscovery tests that the plugins behind the selected Services are
+// dialed, and released once their Service is gone
+func TestServerServiceDiscovery(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	pluginServer := grpc.NewServer()
+	grpc_testing.RegisterTestServiceServer(pluginServer, &metadataTestService{})
+	go func() { _ = pluginServer.Serve(lis) }()
+	defer pluginServer.Stop()
+
+	selected := map[string]string{"plugin": "true"}
+	service := func(name string, labels, annotations map[string]string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "plugins", Labels: labels, Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Ports: ports},
+		}
+	}
+	widgets := service("widgets", map[string]string{"plugin": "true", server.PluginVersionLabel: "v2.0.0"}, nil,
+		corev1.ServicePort{Name: "metrics", Port: 8080},
+		corev1.ServicePort{Name: "grpc", Port: 9000})
+	gadgets := service("gadgets-svc", selected, map[string]string{server.PluginNameAnnotation: "gadgets"},
+		corev1.ServicePort{Name: "http", Port: 7000},
+		corev1.ServicePort{Name: "admin", Port: 7001})
+	headless := service("headless", selected, nil)
+	unselected := service("unselected", nil, nil, corev1.ServicePort{Name: "grpc", Port: 9000})
+	c := fake.NewClientBuilder().WithObjects(widgets, gadgets, headless, unselected).Build()
+
+	dns := &discoveryResolver{}
+	s, _ := startServer(t, server.WithServiceDiscovery(server.ServiceDiscovery{
+		Reader:      c,
+		Namespace:   "plugins",
+		Selector:    labels.SelectorFromSet(selected),
+		Interval:    50 * time.Millisecond,
+		DialOptions: dns.dialOptions(socket),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	waitConnected := func(name string, connected bool) {
+		t.Helper()
+		for s.IsPluginConnected(name) != connected {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected %s connected to be %v", name, connected)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	waitConnected("widgets", true)
+	waitConnected("gadgets", true)
+
+	// The grpc port is dialed, or the first port when there is none
+	want := []string{"dns:///gadgets-svc.plugins.svc:7000", "dns:///widgets.plugins.svc:9000"}
+	if got := dns.resolved(); !slices.Equal(got, want) {
+		t.Errorf("expected targets %v, got %v", want, got)
+	}
+	if info := s.GetStreamManager().GetPluginInfo("widgets"); info == nil || info.Version != "v2.0.0" {
+		t.Errorf("expected widgets at version v2.0.0, got %+v", info)
+	}
+	for _, name := range []string{"gadgets-svc", "headless", "unselected"} {
+		if s.IsPluginConnected(name) {
+			t.Errorf("expected no plugin named %s", name)
+		}
+	}
+
+	// The plugin is released when its Service is gone
+	if err := c.Delete(ctx, widgets); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	waitConnected("widgets", false)
+	if _, err := s.GetRegistry().Get("widgets"); err == nil {
+		t.Error("expected widgets to be unregistered")
+	}
+	if !s.IsPluginConnected("gadgets") {
+		t.Error("expected gadgets to stay connected")
+	}
+}
+
+// TestServerServiceDiscoveryDuplicateNames tests that a single Service is dialed when
+// several across namespaces give the same plugin name
+func TestServerServiceDiscoveryDuplicateNames(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	pluginServer := grpc.NewServer()
+	grpc_testing.RegisterTestServiceServer(pluginServer, &metadataTestService{})
+	go func() { _ = pluginServer.Serve(lis) }()
+	defer pluginServer.Stop()
+
+	service := func(namespace, name string, annotations map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "grpc", Port: 9000}}},
+		}
+	}
+	first := service("team-a", "widgets", nil)
+	c := fake.NewClientBuilder().WithObjects(
+		first,
+		service("team-b", "widgets", nil),
+		service("team-b", "other", map[string]string{server.PluginNameAnnotation: "widgets"}),
+	).Build()
+
+	dns := &discoveryResolver{}
+	s, _ := startServer(t, server.WithServiceDiscovery(server.ServiceDiscovery{
+		Reader:      c,
+		Interval:    20 * time.Millisecond,
+		DialOptions: dns.dialOptions(socket),
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	waitResolved := func(want ...string) {
+		t.Helper()
+		for !slices.Equal(dns.resolved(), want) {
+			select {
+			case <-ctx.Done():
+				t.Fatalf("expected targets %v, got %v", want, dns.resolved())
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	// The first Service by namespace and name is kept over the passes, the others skipped
+	waitResolved("dns:///widgets.team-a.svc:9000")
+	time.Sleep(200 * time.Millisecond)
+	if got := dns.resolved(); !slices.Equal(got, []string{"dns:///widgets.team-a.svc:9000"}) {
+		t.Errorf("expected only the Service of team-a to be dialed, got %v", got)
+	}
+	if !s.IsPluginConnected("widgets") {
+		t.Error("expected widgets to be connected")
+	}
+
+	// Another one takes over once it is gone
+	if err := c.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	waitResolved("dns:///other.team-b.svc:9000", "dns:///widgets.team-a.svc:9000")
+}
+
+// TestServerRetriesRefusedDialedPlugins tests that a dialed plugin refused by a full
+// server is admitted once a connection is free
+func TestServerRetriesRefusedDialedPlugins(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "plugin.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	pluginServer := grpc.NewServer()
+	grpc_testing.RegisterTestServiceServer(pluginServer, &metadataTestService{})
+	go func() { _ = pluginServer.Serve(lis) }()
+	defer pluginServer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Take the only connection before the plugin is dialed
+	s := server.New(fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock")),
+		server.WithMaxConnections(1),
+		server.WithPluginEndpoints(server.PluginEndpoint{Name: "dialed-plugin", Target: "unix://" + socket}))
+	blockerCtx, blockerCancel := context.WithCancel(ctx)
+	defer blockerCancel()
+	go func() { _ = s.GetStreamManager().HandlePluginStream(blockerCtx, "blocker-plugin") }()
+	for !s.IsPluginConnected("blocker-plugin") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- s.Start(ctx) }()
+	defer func() {
+		cancel()
+		<-errs
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if s.IsPluginConnected("dialed-plugin") {
+		t.Fatal("expected dialed-plugin to be refused while the server is full")
+	}
+
+	blockerCancel()
+	for !s.IsPluginConnected("dialed-plugin") {
+		select {
+		case <-ctx.Done():
+			t.Fatal("dialed-plugin was not admitted once a connection was free")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// subprocessTestService answers with the process ID of the plugin, and crashes it on EmptyCall
+type subprocessTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *subprocessTestService) UnaryCall(context.Context, *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return &grpc_testing.SimpleResponse{Username: strconv.Itoa(os.Getpid())}, nil
+}
+
+func (s *subprocessTestService) EmptyCall(context.Context, *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	fmt.Fprintln(os.Stderr, "subprocess plugin crashing")
+	os.Exit(3)
+	return nil, nil
+}
+
+// TestSubprocessPluginHelper is the plugin executable launched by TestServerSubprocessPlugins
+func TestSubprocessPluginHelper(t *testing.T) {
+	if os.Getenv("E2E_SUBPROCESS_PLUGIN") != "1" {
+		t.Skip("only runs as the plugin launched by TestServerSubprocessPlugins")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, "subprocess plugin starting")
+	c, err := client.NewSubprocess(ctx, "v1.0.0", grpc_testing.TestService_ServiceDesc, &subprocessTestService{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_ = c.HandleRPCCalls(ctx)
+	os.Exit(0)
+}
+
+// TestServerSubprocessPlugins tests that the server launches a plugin executable, calls it
+// like a plugin connecting to it, forwards its output and restarts it when it crashes
+func TestServerSubprocessPlugins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	if _, err := client.NewSubprocess(ctx, "v1.0.0", grpc_testing.TestService_ServiceDesc, &subprocessTestService{}); !errors.Is(err, client.ErrNotSubprocess) {
+		t.Errorf("expected ErrNotSubprocess outside of a launched plugin, got %v", err)
+	}
+
+	var logsMu sync.Mutex
+	var logs []string
+	logger := funcr.New(func(_, args string) {
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		logs = append(logs, args)
+	}, funcr.Options{})
+	logged := func(text string) bool {
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		return slices.ContainsFunc(logs, func(line string) bool { return strings.Contains(line, text) })
+	}
+
+	addr := fmt.Sprintf("unix:///%s", filepath.Join(t.TempDir(), "server.sock"))
+	s := server.New(addr, server.WithSubprocessPlugins(server.SubprocessPlugin{
+		Name:           "subprocess-plugin",
+		Path:           os.Args[0],
+		Args:           []string{"-test.run=^TestSubprocessPluginHelper$"},
+		Env:            []string{"E2E_SUBPROCESS_PLUGIN=1"},
+		InitialBackoff: 50 * time.Millisecond,
+	}))
+	serverCtx, stopServer := context.WithCancel(log.IntoContext(ctx, logger))
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(serverCtx)
+	}()
+	defer func() {
+		stopServer()
+		<-errs
+	}()
+
+	// pid calls the plugin process once it is connected, and returns its process ID
+	pid := func() string {
+		t.Helper()
+		for {
+			if conn, err := s.PluginConn("subprocess-plugin"); err == nil {
+				resp, err := grpc_testing.NewTestServiceClient(conn).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+				if err == nil {
+					return resp.GetUsername()
+				}
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatal("subprocess plugin never answered")
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	first := pid()
+	if !logged("subprocess plugin starting") {
+		t.Error("expected the output of the plugin to be logged")
+	}
+
+	// A crashed plugin is launched again
+	conn, err := s.PluginConn("subprocess-plugin")
+	if err != nil {
+		t.Fatalf("PluginConn() error = %v", err)
+	}
+	if _, err := grpc_testing.NewTestServiceClient(conn).EmptyCall(ctx, &grpc_testing.Empty{}); err == nil {
+		t.Fatal("expected the crashing call to fail")
+	}
+	for {
+		if second := pid(); second != first {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !logged("subprocess plugin crashing") {
+		t.Error("expected the output of the crashed plugin to be logged")
+	}
+}
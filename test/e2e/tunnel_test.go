@@ -0,0 +1,2026 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	pluginframeworkv1 "github.com/guilhem/operator-plugin-framework/pluginframework/v1"
+	"github.com/guilhem/operator-plugin-framework/stream"
+)
+
+// tunnelService is a minimal PluginFrameworkService that hands every plugin stream to the test.
+type tunnelService struct {
+	pluginframeworkv1.UnimplementedPluginFrameworkServiceServer
+	managers chan *stream.StreamManager
+	opts     []stream.Option
+}
+
+func (s *tunnelService) PluginStream(gs grpc.BidiStreamingServer[pluginframeworkv1.PluginStreamMessage, pluginframeworkv1.PluginStreamMessage]) error {
+	sm, err := stream.NewStreamManager(gs, s.opts...)
+	if err != nil {
+		return err
+	}
+	s.managers <- sm
+	return sm.ListenForMessages(gs.Context())
+}
+
+// startTunnel connects a plugin serving impl to an operator-side StreamManager
+// over a real gRPC stream and returns the operator side. Options are given to both sides.
+func startTunnel(t *testing.T, impl grpc_testing.TestServiceServer, opts ...stream.Option) *stream.StreamManager {
+	t.Helper()
+
+	svc := &tunnelService{managers: make(chan *stream.StreamManager, 1), opts: opts}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	pluginStream, err := frameworkClient.PluginStream(ctx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "test-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, impl, opts...)
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	go func() { _ = psc.HandleRPCCalls(ctx) }()
+
+	return nextManager(t, svc)
+}
+
+// serveTunnel serves svc on a temporary socket and returns a client connected to it.
+func serveTunnel(t *testing.T, svc *tunnelService) pluginframeworkv1.PluginFrameworkServiceClient {
+	t.Helper()
+
+	sockPath := filepath.Join(t.TempDir(), "tunnel.sock")
+	lis, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	gs := grpc.NewServer()
+	pluginframeworkv1.RegisterPluginFrameworkServiceServer(gs, svc)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("unix://%s", sockPath), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return pluginframeworkv1.NewPluginFrameworkServiceClient(conn)
+}
+
+// nextManager returns the operator side of the next plugin stream registered on svc.
+func nextManager(t *testing.T, svc *tunnelService) *stream.StreamManager {
+	t.Helper()
+
+	select {
+	case sm := <-svc.managers:
+		return sm
+	case <-time.After(5 * time.Second):
+		t.Fatal("plugin did not register")
+		return nil
+	}
+}
+
+// metadataTestService echoes incoming metadata back as header and trailer.
+type metadataTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *metadataTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-echo", md.Get("x-request")[0])); err != nil {
+		return nil, err
+	}
+	if err := grpc.SetTrailer(ctx, metadata.Pairs("x-trailer-bin", string([]byte{0xff, 0x00}))); err != nil {
+		return nil, err
+	}
+	return &grpc_testing.SimpleResponse{Username: md.Get("x-user")[0]}, nil
+}
+
+// TestTunnelMetadata tests that metadata flows to the plugin and header/trailer flow back
+func TestTunnelMetadata(t *testing.T) {
+	sm := startTunnel(t, &metadataTestService{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request", "hello", "x-user", "alice")
+
+	var header, trailer metadata.MD
+	respBytes, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{},
+		grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatalf("CallRPC() error = %v", err)
+	}
+
+	resp := &grpc_testing.SimpleResponse{}
+	if err := proto.Unmarshal(respBytes, resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.GetUsername() != "alice" {
+		t.Errorf("expected username alice, got %q", resp.GetUsername())
+	}
+
+	if got := header.Get("x-echo"); len(got) != 1 || got[0] != "hello" {
+		t.Errorf("expected header x-echo=hello, got %v", got)
+	}
+	if got := trailer.Get("x-trailer-bin"); len(got) != 1 || got[0] != string([]byte{0xff, 0x00}) {
+		t.Errorf("expected binary trailer, got %v", got)
+	}
+}
+
+// blockingTestService blocks in its handlers until their context is done.
+type blockingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	deadlines chan time.Time
+	cancelled chan error
+}
+
+func (s *blockingTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	deadline, _ := ctx.Deadline()
+	s.deadlines <- deadline
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+// TestTunnelDeadlinePropagation tests that the caller's deadline is applied to the plugin handler
+func TestTunnelDeadlinePropagation(t *testing.T) {
+	svc := &blockingTestService{deadlines: make(chan time.Time, 1), cancelled: make(chan error, 1)}
+	sm := startTunnel(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+	if !stream.IsDeadlineExceeded(err) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	deadline := <-svc.deadlines
+	if deadline.IsZero() {
+		t.Fatal("handler context should have a deadline")
+	}
+	if until := time.Until(deadline); until > 300*time.Millisecond {
+		t.Errorf("handler deadline too far in the future: %v", until)
+	}
+
+	select {
+	case <-svc.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler context was not done after the deadline")
+	}
+}
+
+// TestTunnelRemoteCancellation tests that cancelling the caller cancels the plugin handler
+func TestTunnelRemoteCancellation(t *testing.T) {
+	svc := &blockingTestService{deadlines: make(chan time.Time, 1), cancelled: make(chan error, 1)}
+	sm := startTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		errs <- err
+	}()
+
+	if deadline := <-svc.deadlines; !deadline.IsZero() {
+		t.Errorf("handler context should not have a deadline, got %v", deadline)
+	}
+
+	cancel()
+	if err := <-errs; status.Code(err) != codes.Canceled {
+		t.Errorf("expected Canceled, got %v", err)
+	}
+
+	select {
+	case err := <-svc.cancelled:
+		if err != context.Canceled {
+			t.Errorf("expected handler context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler context was not cancelled by the operator")
+	}
+}
+
+// failingTestService returns errors from its handlers.
+type failingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *failingTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	if err := grpc.SetTrailer(ctx, metadata.Pairs("x-reason", "missing")); err != nil {
+		return nil, err
+	}
+	st, err := status.New(codes.NotFound, "resource not found").WithDetails(&errdetails.ErrorInfo{Reason: "MISSING", Domain: "test"})
+	if err != nil {
+		return nil, err
+	}
+	return nil, st.Err()
+}
+
+func (s *failingTestService) EmptyCall(ctx context.Context, req *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	return nil, fmt.Errorf("plain error")
+}
+
+// TestTunnelErrors tests that handler errors reach the caller as correlated status errors
+func TestTunnelErrors(t *testing.T) {
+	sm := startTunnel(t, &failingTestService{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var trailer metadata.MD
+	_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{}, grpc.Trailer(&trailer))
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound || st.Message() != "resource not found" {
+		t.Errorf("expected NotFound status, got %v", err)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("expected 1 status detail, got %d", len(st.Details()))
+	}
+	if info, ok := st.Details()[0].(*errdetails.ErrorInfo); !ok || info.GetReason() != "MISSING" {
+		t.Errorf("expected ErrorInfo detail with reason MISSING, got %v", st.Details()[0])
+	}
+	if got := trailer.Get("x-reason"); len(got) != 1 || got[0] != "missing" {
+		t.Errorf("expected trailer x-reason=missing, got %v", got)
+	}
+
+	_, err = sm.CallRPC(ctx, grpc_testing.TestService_EmptyCall_FullMethodName, &grpc_testing.Empty{})
+	if status.Code(err) != codes.Unknown {
+		t.Errorf("expected Unknown for plain error, got %v", err)
+	}
+
+	_, err = sm.CallRPC(ctx, "/grpc.testing.TestService/DoesNotExist", &grpc_testing.Empty{})
+	if !stream.IsMethodUnimplemented(err) {
+		t.Errorf("expected Unimplemented for unknown method, got %v", err)
+	}
+}
+
+// nilResponseTestService returns no response and no error from its handler.
+type nilResponseTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *nilResponseTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return nil, nil
+}
+
+// TestTunnelNilResponse tests that a handler returning neither a response nor an error
+// fails the call with Internal, without bringing the plugin down
+func TestTunnelNilResponse(t *testing.T) {
+	sm := startTunnel(t, &nilResponseTestService{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for range 2 {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		if status.Code(err) != codes.Internal {
+			t.Errorf("expected Internal for a nil response, got %v", err)
+		}
+	}
+}
+
+// streamingTestService streams one response per requested parameter.
+type streamingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *streamingTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return &grpc_testing.SimpleResponse{Username: "unary"}, nil
+}
+
+func (s *streamingTestService) StreamingOutputCall(req *grpc_testing.StreamingOutputCallRequest, ss grpc.ServerStreamingServer[grpc_testing.StreamingOutputCallResponse]) error {
+	if err := ss.SendHeader(metadata.Pairs("x-count", fmt.Sprint(len(req.GetResponseParameters())))); err != nil {
+		return err
+	}
+	for _, p := range req.GetResponseParameters() {
+		if p.GetSize() < 0 {
+			return status.Error(codes.InvalidArgument, "negative size")
+		}
+		resp := &grpc_testing.StreamingOutputCallResponse{
+			Payload: &grpc_testing.Payload{Body: make([]byte, p.GetSize())},
+		}
+		if err := ss.Send(resp); err != nil {
+			return err
+		}
+	}
+	ss.SetTrailer(metadata.Pairs("x-done", "true"))
+	return nil
+}
+
+// TestTunnelServerStreaming tests server-streaming calls through a generated client
+func TestTunnelServerStreaming(t *testing.T) {
+	sm := startTunnel(t, &streamingTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Unary calls work through the generated client too
+	unary, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil || unary.GetUsername() != "unary" {
+		t.Fatalf("UnaryCall() = %v, %v", unary, err)
+	}
+
+	numMessages := 100
+	req := &grpc_testing.StreamingOutputCallRequest{}
+	for i := 0; i < numMessages; i++ {
+		req.ResponseParameters = append(req.ResponseParameters, &grpc_testing.ResponseParameters{Size: int32(i)})
+	}
+
+	st, err := client.StreamingOutputCall(ctx, req)
+	if err != nil {
+		t.Fatalf("StreamingOutputCall() error = %v", err)
+	}
+
+	header, err := st.Header()
+	if err != nil {
+		t.Fatalf("Header() error = %v", err)
+	}
+	if got := header.Get("x-count"); len(got) != 1 || got[0] != fmt.Sprint(numMessages) {
+		t.Errorf("expected header x-count=%d, got %v", numMessages, got)
+	}
+
+	for i := 0; ; i++ {
+		resp, err := st.Recv()
+		if err == io.EOF {
+			if i != numMessages {
+				t.Errorf("expected %d messages, got %d", numMessages, i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if len(resp.GetPayload().GetBody()) != i {
+			t.Errorf("message %d: expected body size %d, got %d", i, i, len(resp.GetPayload().GetBody()))
+		}
+	}
+
+	if got := st.Trailer().Get("x-done"); len(got) != 1 || got[0] != "true" {
+		t.Errorf("expected trailer x-done=true, got %v", got)
+	}
+}
+
+// TestTunnelServerStreamingError tests that a failing stream handler ends the stream with its status
+func TestTunnelServerStreamingError(t *testing.T) {
+	sm := startTunnel(t, &streamingTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := &grpc_testing.StreamingOutputCallRequest{
+		ResponseParameters: []*grpc_testing.ResponseParameters{{Size: 1}, {Size: -1}},
+	}
+	st, err := client.StreamingOutputCall(ctx, req)
+	if err != nil {
+		t.Fatalf("StreamingOutputCall() error = %v", err)
+	}
+
+	if _, err := st.Recv(); err != nil {
+		t.Fatalf("first Recv() error = %v", err)
+	}
+	if _, err := st.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// duplexTestService implements the client-streaming and bidirectional test methods.
+type duplexTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	sent atomic.Int64
+}
+
+func (s *duplexTestService) StreamingInputCall(ss grpc.ClientStreamingServer[grpc_testing.StreamingInputCallRequest, grpc_testing.StreamingInputCallResponse]) error {
+	total := 0
+	for {
+		req, err := ss.Recv()
+		if err == io.EOF {
+			return ss.SendAndClose(&grpc_testing.StreamingInputCallResponse{AggregatedPayloadSize: int32(total)})
+		}
+		if err != nil {
+			return err
+		}
+		total += len(req.GetPayload().GetBody())
+	}
+}
+
+func (s *duplexTestService) FullDuplexCall(ss grpc.BidiStreamingServer[grpc_testing.StreamingOutputCallRequest, grpc_testing.StreamingOutputCallResponse]) error {
+	for {
+		req, err := ss.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, p := range req.GetResponseParameters() {
+			if err := ss.Send(&grpc_testing.StreamingOutputCallResponse{Payload: &grpc_testing.Payload{Body: make([]byte, p.GetSize())}}); err != nil {
+				return err
+			}
+			s.sent.Add(1)
+		}
+	}
+}
+
+// TestTunnelClientStreaming tests client-streaming calls with half-close
+func TestTunnelClientStreaming(t *testing.T) {
+	sm := startTunnel(t, &duplexTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	st, err := client.StreamingInputCall(ctx)
+	if err != nil {
+		t.Fatalf("StreamingInputCall() error = %v", err)
+	}
+
+	// Send more messages than the flow-control window
+	expected := 0
+	for i := 0; i < 200; i++ {
+		if err := st.Send(&grpc_testing.StreamingInputCallRequest{Payload: &grpc_testing.Payload{Body: make([]byte, i)}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		expected += i
+	}
+
+	resp, err := st.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv() error = %v", err)
+	}
+	if int(resp.GetAggregatedPayloadSize()) != expected {
+		t.Errorf("expected aggregated size %d, got %d", expected, resp.GetAggregatedPayloadSize())
+	}
+}
+
+// TestTunnelBidiStreamingMultiplexed tests concurrent bidirectional calls over one plugin stream
+func TestTunnelBidiStreamingMultiplexed(t *testing.T) {
+	sm := startTunnel(t, &duplexTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	numCalls := 10
+	numMessages := 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, numCalls)
+	for c := 0; c < numCalls; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+
+			st, err := client.FullDuplexCall(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			go func() {
+				for i := 0; i < numMessages; i++ {
+					req := &grpc_testing.StreamingOutputCallRequest{
+						ResponseParameters: []*grpc_testing.ResponseParameters{{Size: int32(c)}},
+					}
+					if err := st.Send(req); err != nil {
+						return
+					}
+				}
+				_ = st.CloseSend()
+			}()
+
+			received := 0
+			for {
+				resp, err := st.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(resp.GetPayload().GetBody()) != c {
+					errs <- fmt.Errorf("call %d: got a message of another call", c)
+					return
+				}
+				received++
+			}
+			if received != numMessages {
+				errs <- fmt.Errorf("call %d: expected %d messages, got %d", c, numMessages, received)
+			}
+		}(c)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestTunnelStreamFlowControl tests that a plugin cannot send more than the window to a slow reader
+func TestTunnelStreamFlowControl(t *testing.T) {
+	svc := &duplexTestService{}
+	sm := startTunnel(t, svc)
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	st, err := client.FullDuplexCall(ctx)
+	if err != nil {
+		t.Fatalf("FullDuplexCall() error = %v", err)
+	}
+
+	req := &grpc_testing.StreamingOutputCallRequest{}
+	for i := 0; i < 500; i++ {
+		req.ResponseParameters = append(req.ResponseParameters, &grpc_testing.ResponseParameters{Size: 1})
+	}
+	if err := st.Send(req); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := st.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error = %v", err)
+	}
+
+	// Without reading, the plugin must stop at the window
+	time.Sleep(200 * time.Millisecond)
+	if sent := svc.sent.Load(); sent > 64 {
+		t.Errorf("plugin sent %d messages to a reader that read none", sent)
+	}
+
+	received := 0
+	for {
+		_, err := st.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		received++
+	}
+	if received != 500 {
+		t.Errorf("expected 500 messages, got %d", received)
+	}
+}
+
+// gatedTestService blocks its handlers until released.
+type gatedTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	started chan string
+	release chan struct{}
+}
+
+func (s *gatedTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	s.started <- "UnaryCall"
+	<-s.release
+	return &grpc_testing.SimpleResponse{}, nil
+}
+
+func (s *gatedTestService) EmptyCall(ctx context.Context, req *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	s.started <- "EmptyCall"
+	<-s.release
+	return &grpc_testing.Empty{}, nil
+}
+
+// TestTunnelConcurrencyLimits tests bounded concurrency, queueing and rejection on the plugin side
+func TestTunnelConcurrencyLimits(t *testing.T) {
+	svc := &gatedTestService{started: make(chan string, 10), release: make(chan struct{})}
+	sm := startTunnel(t, svc, stream.WithMaxConcurrentCalls(2), stream.WithMaxQueuedCalls(1))
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 3)
+	call := func() {
+		_, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+		errs <- err
+	}
+
+	// Two calls run, a third one waits in the queue
+	go call()
+	go call()
+	<-svc.started
+	<-svc.started
+	go call()
+	time.Sleep(100 * time.Millisecond)
+
+	// The queue is full: the next call is rejected as retryable
+	_, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if !stream.IsPluginOverloaded(err) || !stream.IsRetryable(err) {
+		t.Fatalf("expected retryable ResourceExhausted, got %v", err)
+	}
+	if delay, ok := stream.RetryDelay(err); !ok || delay <= 0 {
+		t.Errorf("expected a retry delay, got %v, %v", delay, ok)
+	}
+
+	select {
+	case <-svc.started:
+		t.Fatal("queued call should not have started")
+	default:
+	}
+
+	// Releasing the handlers lets the queued call run
+	close(svc.release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("call %d error = %v", i, err)
+		}
+	}
+}
+
+// TestTunnelMethodConcurrencyLimit tests per-method limits
+func TestTunnelMethodConcurrencyLimit(t *testing.T) {
+	svc := &gatedTestService{started: make(chan string, 10), release: make(chan struct{})}
+	sm := startTunnel(t, svc, stream.WithMethodConcurrencyLimit(grpc_testing.TestService_EmptyCall_FullMethodName, 1))
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 3)
+	go func() {
+		_, err := client.EmptyCall(ctx, &grpc_testing.Empty{})
+		errs <- err
+	}()
+	if started := <-svc.started; started != "EmptyCall" {
+		t.Fatalf("expected EmptyCall to start, got %s", started)
+	}
+
+	// A second EmptyCall waits, while other methods still run
+	go func() {
+		_, err := client.EmptyCall(ctx, &grpc_testing.Empty{})
+		errs <- err
+	}()
+	go func() {
+		_, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+		errs <- err
+	}()
+	if started := <-svc.started; started != "UnaryCall" {
+		t.Fatalf("expected UnaryCall to start, got %s", started)
+	}
+
+	close(svc.release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("call %d error = %v", i, err)
+		}
+	}
+}
+
+// TestTunnelConcurrentLoad tests many concurrent unary and streaming calls sharing the stream
+func TestTunnelConcurrentLoad(t *testing.T) {
+	sm := startTunnel(t, &streamingTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	numWorkers := 50
+	numCalls := 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, numWorkers)
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < numCalls; i++ {
+				if w%2 == 0 {
+					if _, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{}); err != nil {
+						errs <- err
+						return
+					}
+					continue
+				}
+
+				req := &grpc_testing.StreamingOutputCallRequest{}
+				for j := 0; j < 10; j++ {
+					req.ResponseParameters = append(req.ResponseParameters, &grpc_testing.ResponseParameters{Size: 128})
+				}
+				st, err := client.StreamingOutputCall(ctx, req)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for {
+					if _, err := st.Recv(); err == io.EOF {
+						break
+					} else if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// stalledStream is a plugin stream whose peer registered and then stopped reading:
+// Send blocks until the stream is torn down.
+type stalledStream struct {
+	ctx        context.Context
+	registered bool
+}
+
+func (s *stalledStream) Send(*pluginframeworkv1.PluginStreamMessage) error {
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func (s *stalledStream) Recv() (*pluginframeworkv1.PluginStreamMessage, error) {
+	if !s.registered {
+		s.registered = true
+		return &pluginframeworkv1.PluginStreamMessage{
+			Payload: &pluginframeworkv1.PluginStreamMessage_Register{
+				Register: &pluginframeworkv1.PluginRegister{Name: "stalled", Version: "v1.0.0"},
+			},
+		}, nil
+	}
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+func (s *stalledStream) Context() context.Context {
+	return s.ctx
+}
+
+// TestTunnelSlowConsumer tests that a plugin that stops reading is detected and disconnected
+func TestTunnelSlowConsumer(t *testing.T) {
+	streamCtx, streamCancel := context.WithCancel(context.Background())
+	defer streamCancel()
+
+	sm, err := stream.NewStreamManager(&stalledStream{ctx: streamCtx}, stream.WithSlowConsumerTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewStreamManager() error = %v", err)
+	}
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- sm.ListenForMessages(context.Background()) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	go func() {
+		_, _ = sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+	}()
+
+	select {
+	case err := <-listenErr:
+		if !errors.Is(err, stream.ErrSlowConsumer) {
+			t.Errorf("expected ErrSlowConsumer, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow consumer was not detected")
+	}
+
+	// The stream is abandoned: new calls fail right away
+	_, err = sm.CallRPC(context.Background(), grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+	if !stream.IsPluginUnavailable(err) {
+		t.Errorf("expected Unavailable after disconnect, got %v", err)
+	}
+}
+
+// pipeStream is an operator-side plugin stream driven by the test: the plugin has
+// registered, messages sent to it are delivered on out, and the test feeds the
+// plugin's messages on in. Closing in ends the stream.
+type pipeStream struct {
+	registered bool
+	in         chan *pluginframeworkv1.PluginStreamMessage
+	out        chan *pluginframeworkv1.PluginStreamMessage
+}
+
+func newPipeStream() *pipeStream {
+	return &pipeStream{
+		in:  make(chan *pluginframeworkv1.PluginStreamMessage),
+		out: make(chan *pluginframeworkv1.PluginStreamMessage, 100),
+	}
+}
+
+func (s *pipeStream) Send(msg *pluginframeworkv1.PluginStreamMessage) error {
+	s.out <- msg
+	return nil
+}
+
+func (s *pipeStream) Recv() (*pluginframeworkv1.PluginStreamMessage, error) {
+	if !s.registered {
+		s.registered = true
+		return &pluginframeworkv1.PluginStreamMessage{
+			Payload: &pluginframeworkv1.PluginStreamMessage_Register{
+				Register: &pluginframeworkv1.PluginRegister{
+					Name:            "pipe",
+					Version:         "v1.0.0",
+					ProtocolVersion: stream.ProtocolVersion,
+					Features:        []string{stream.FeatureStreaming, stream.FeaturePayloadChunks},
+				},
+			},
+		}, nil
+	}
+	msg, ok := <-s.in
+	if !ok {
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+func (s *pipeStream) Context() context.Context {
+	return context.Background()
+}
+
+// nextCall returns the next RPC call sent to the plugin.
+func (s *pipeStream) nextCall(t *testing.T) *pluginframeworkv1.PluginRPCCall {
+	t.Helper()
+
+	for {
+		select {
+		case msg := <-s.out:
+			if call := msg.GetRpcCall(); call != nil {
+				return call
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no call was sent to the plugin")
+			return nil
+		}
+	}
+}
+
+// TestTunnelStreamLoss tests that losing the stream fails pending calls right away
+func TestTunnelStreamLoss(t *testing.T) {
+	ps := newPipeStream()
+	sm, err := stream.NewStreamManager(ps)
+	if err != nil {
+		t.Fatalf("NewStreamManager() error = %v", err)
+	}
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- sm.ListenForMessages(context.Background()) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		callErr <- err
+	}()
+	ps.nextCall(t)
+
+	st, err := grpc_testing.NewTestServiceClient(sm).StreamingOutputCall(ctx, &grpc_testing.StreamingOutputCallRequest{})
+	if err != nil {
+		t.Fatalf("StreamingOutputCall() error = %v", err)
+	}
+	ps.nextCall(t)
+
+	start := time.Now()
+	close(ps.in)
+
+	select {
+	case err := <-callErr:
+		if !stream.IsPluginUnavailable(err) {
+			t.Errorf("expected Unavailable for pending call, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call was not failed when the stream was lost")
+	}
+	if _, err := st.Recv(); !stream.IsPluginUnavailable(err) {
+		t.Errorf("expected Unavailable for open stream, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("calls took %v to fail", elapsed)
+	}
+	if err := <-listenErr; err == nil {
+		t.Error("expected ListenForMessages to report the stream loss")
+	}
+
+	_, err = sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+	if !stream.IsPluginUnavailable(err) {
+		t.Errorf("expected Unavailable for a call after the loss, got %v", err)
+	}
+}
+
+// TestTunnelStrayResponse tests that a response to an unknown request does not close the stream
+func TestTunnelStrayResponse(t *testing.T) {
+	ps := newPipeStream()
+	sm, err := stream.NewStreamManager(ps)
+	if err != nil {
+		t.Fatalf("NewStreamManager() error = %v", err)
+	}
+	go func() { _ = sm.ListenForMessages(context.Background()) }()
+	defer close(ps.in)
+
+	ps.in <- &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
+			RpcResponse: &pluginframeworkv1.PluginRPCResponse{RequestId: "unknown"},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		callErr <- err
+	}()
+
+	call := ps.nextCall(t)
+	ps.in <- &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
+			RpcResponse: &pluginframeworkv1.PluginRPCResponse{RequestId: call.GetRequestId()},
+		},
+	}
+
+	if err := <-callErr; err != nil {
+		t.Errorf("CallRPC() error = %v", err)
+	}
+}
+
+// TestTunnelConcurrentRequestIDs tests that concurrent calls never get each other's responses
+func TestTunnelConcurrentRequestIDs(t *testing.T) {
+	sm := startTunnel(t, &metadataTestService{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	numCalls := 200
+	var wg sync.WaitGroup
+	errs := make(chan error, numCalls)
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			user := fmt.Sprintf("user-%d", i)
+			callCtx := metadata.AppendToOutgoingContext(ctx, "x-request", "id", "x-user", user)
+			respBytes, err := sm.CallRPC(callCtx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp := &grpc_testing.SimpleResponse{}
+			if err := proto.Unmarshal(respBytes, resp); err != nil {
+				errs <- err
+				return
+			}
+			if resp.GetUsername() != user {
+				errs <- fmt.Errorf("call %d got the response of %s", i, resp.GetUsername())
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// countingTestService fails the first calls with UNAVAILABLE and counts handler runs.
+type countingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	failures int64
+	runs     atomic.Int64
+	keys     chan string
+	release  chan struct{}
+}
+
+func (s *countingTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	run := s.runs.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if run <= s.failures {
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &grpc_testing.SimpleResponse{Username: fmt.Sprintf("run-%d", run)}, nil
+}
+
+// TestTunnelRetryPolicy tests that calls are retried according to the method's policy
+func TestTunnelRetryPolicy(t *testing.T) {
+	policy := stream.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("succeeds within the attempts", func(t *testing.T) {
+		svc := &countingTestService{failures: 2}
+		sm := startTunnel(t, svc, stream.WithRetryPolicy("UnaryCall", policy))
+
+		if _, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{}); err != nil {
+			t.Fatalf("CallRPC() error = %v", err)
+		}
+		if runs := svc.runs.Load(); runs != 3 {
+			t.Errorf("expected 3 attempts, got %d", runs)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		svc := &countingTestService{failures: 5}
+		sm := startTunnel(t, svc, stream.WithRetryPolicy("", policy))
+
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+		if runs := svc.runs.Load(); runs != 3 {
+			t.Errorf("expected 3 attempts, got %d", runs)
+		}
+	})
+
+	t.Run("other methods are not retried", func(t *testing.T) {
+		svc := &countingTestService{failures: 1}
+		sm := startTunnel(t, svc, stream.WithRetryPolicy("EmptyCall", policy))
+
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+		if runs := svc.runs.Load(); runs != 1 {
+			t.Errorf("expected 1 attempt, got %d", runs)
+		}
+	})
+}
+
+// TestTunnelIdempotency tests that the plugin runs a handler once per idempotency key
+func TestTunnelIdempotency(t *testing.T) {
+	svc := &countingTestService{release: make(chan struct{})}
+	sm := startTunnel(t, svc, stream.WithIdempotencyCache(time.Minute, 10))
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A duplicate arriving while the first call runs waits for its response
+	responses := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{}, stream.IdempotencyKey("key-1"))
+			if err != nil {
+				t.Errorf("UnaryCall() error = %v", err)
+			}
+			responses <- resp.GetUsername()
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(svc.release)
+
+	first, second := <-responses, <-responses
+	if first != "run-1" || second != "run-1" {
+		t.Errorf("expected both calls to get run-1, got %q and %q", first, second)
+	}
+
+	// A later retry gets the stored response
+	resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{}, stream.IdempotencyKey("key-1"))
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "run-1" {
+		t.Errorf("expected stored response run-1, got %q", resp.GetUsername())
+	}
+	if runs := svc.runs.Load(); runs != 1 {
+		t.Errorf("expected the handler to run once, got %d", runs)
+	}
+
+	// Another key runs the handler
+	resp, err = client.UnaryCall(ctx, &grpc_testing.SimpleRequest{}, stream.IdempotencyKey("key-2"))
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "run-2" {
+		t.Errorf("expected run-2, got %q", resp.GetUsername())
+	}
+}
+
+// echoTestService answers with a payload of the requested size, and echoes the request payload otherwise.
+type echoTestService struct {
+	duplexTestService
+}
+
+func (s *echoTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	if size := req.GetResponseSize(); size > 0 {
+		return &grpc_testing.SimpleResponse{Payload: &grpc_testing.Payload{Body: make([]byte, size)}}, nil
+	}
+	return &grpc_testing.SimpleResponse{Payload: req.GetPayload()}, nil
+}
+
+// TestTunnelLargePayloads tests payloads over the gRPC message size limit while other calls keep flowing
+func TestTunnelLargePayloads(t *testing.T) {
+	sm := startTunnel(t, &echoTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	body := make([]byte, 10<<20)
+	for i := range body {
+		body[i] = byte(i)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: body}})
+		if err != nil {
+			errs <- fmt.Errorf("large call: %w", err)
+			return
+		}
+		if !bytes.Equal(resp.GetPayload().GetBody(), body) {
+			errs <- fmt.Errorf("large call: payload was not echoed intact")
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		st, err := client.FullDuplexCall(ctx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		req := &grpc_testing.StreamingOutputCallRequest{
+			ResponseParameters: []*grpc_testing.ResponseParameters{{Size: 6 << 20}, {Size: 6 << 20}},
+		}
+		if err := st.Send(req); err != nil {
+			errs <- err
+			return
+		}
+		_ = st.CloseSend()
+		for i := 0; i < 2; i++ {
+			resp, err := st.Recv()
+			if err != nil {
+				errs <- fmt.Errorf("large stream message: %w", err)
+				return
+			}
+			if len(resp.GetPayload().GetBody()) != 6<<20 {
+				errs <- fmt.Errorf("large stream message: got %d bytes", len(resp.GetPayload().GetBody()))
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: []byte("small")}}); err != nil {
+				errs <- fmt.Errorf("small call: %w", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestTunnelPayloadLimit tests that payloads over the configured limit fail the call only
+func TestTunnelPayloadLimit(t *testing.T) {
+	sm := startTunnel(t, &echoTestService{}, stream.WithMaxPayloadSize(1<<20), stream.WithMaxChunkSize(64<<10))
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: make([]byte, 2<<20)}})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for a large request, got %v", err)
+	}
+
+	_, err = client.UnaryCall(ctx, &grpc_testing.SimpleRequest{ResponseSize: 2 << 20})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for a large response, got %v", err)
+	}
+
+	resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{ResponseSize: 512 << 10})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if len(resp.GetPayload().GetBody()) != 512<<10 {
+		t.Errorf("expected a chunked response of %d bytes, got %d", 512<<10, len(resp.GetPayload().GetBody()))
+	}
+}
+
+// TestTunnelReceivedPayloadLimit tests that the operator rejects a response whose chunks exceed its limit
+func TestTunnelReceivedPayloadLimit(t *testing.T) {
+	ps := newPipeStream()
+	sm, err := stream.NewStreamManager(ps, stream.WithMaxPayloadSize(100))
+	if err != nil {
+		t.Fatalf("NewStreamManager() error = %v", err)
+	}
+	go func() { _ = sm.ListenForMessages(context.Background()) }()
+	defer close(ps.in)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		callErr <- err
+	}()
+
+	call := ps.nextCall(t)
+	for i := 0; i < 3; i++ {
+		ps.in <- &pluginframeworkv1.PluginStreamMessage{
+			Payload: &pluginframeworkv1.PluginStreamMessage_PayloadChunk{
+				PayloadChunk: &pluginframeworkv1.PluginPayloadChunk{RequestId: call.GetRequestId(), Index: uint32(i), Data: make([]byte, 50)},
+			},
+		}
+	}
+	ps.in <- &pluginframeworkv1.PluginStreamMessage{
+		Payload: &pluginframeworkv1.PluginStreamMessage_RpcResponse{
+			RpcResponse: &pluginframeworkv1.PluginRPCResponse{RequestId: call.GetRequestId(), PayloadChunks: 3},
+		},
+	}
+
+	if err := <-callErr; status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+// compressedBytes returns the bytes compressed with algorithm so far, per the stream metrics.
+func compressedBytes(t *testing.T, algorithm, stage string) float64 {
+	t.Helper()
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "plugin_stream_compression_bytes_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["algorithm"] == algorithm && labels["stage"] == stage {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+// TestTunnelCompression tests negotiated payload compression in both directions
+func TestTunnelCompression(t *testing.T) {
+	body := bytes.Repeat([]byte("apiVersion: v1\nkind: ConfigMap\n"), 4096)
+
+	for _, algorithm := range []string{stream.CompressionGzip, stream.CompressionZstd} {
+		t.Run(algorithm, func(t *testing.T) {
+			sm := startTunnel(t, &echoTestService{}, stream.WithCompression("lz4", algorithm))
+			client := grpc_testing.NewTestServiceClient(sm)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			before := compressedBytes(t, algorithm, "uncompressed")
+			resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: body}})
+			if err != nil {
+				t.Fatalf("UnaryCall() error = %v", err)
+			}
+			if !bytes.Equal(resp.GetPayload().GetBody(), body) {
+				t.Fatal("payload was not echoed intact")
+			}
+
+			// Both the request and the echoed response were compressed
+			if got := compressedBytes(t, algorithm, "uncompressed") - before; got < float64(2*len(body)) {
+				t.Errorf("expected at least %d bytes compressed with %s, got %v", 2*len(body), algorithm, got)
+			}
+		})
+	}
+
+	t.Run("no common algorithm", func(t *testing.T) {
+		sm := startTunnel(t, &echoTestService{}, stream.WithCompression("lz4"))
+		client := grpc_testing.NewTestServiceClient(sm)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		resp, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: body}})
+		if err != nil {
+			t.Fatalf("UnaryCall() error = %v", err)
+		}
+		if !bytes.Equal(resp.GetPayload().GetBody(), body) {
+			t.Fatal("payload was not echoed intact")
+		}
+		if got := compressedBytes(t, "lz4", "uncompressed"); got != 0 {
+			t.Errorf("expected no lz4 compression, got %v bytes", got)
+		}
+	})
+}
+
+// TestTunnelMethodAdvertisement tests that the plugin advertises its methods and file
+// descriptors, and that calls to other methods fail without reaching the plugin
+func TestTunnelMethodAdvertisement(t *testing.T) {
+	impl := &countingTestService{}
+	sm := startTunnel(t, impl, stream.WithFileDescriptors())
+
+	if !slices.Contains(sm.Methods(), grpc_testing.TestService_UnaryCall_FullMethodName) {
+		t.Errorf("expected UnaryCall to be advertised, got %v", sm.Methods())
+	}
+	if !slices.Contains(sm.Methods(), grpc_testing.TestService_FullDuplexCall_FullMethodName) {
+		t.Errorf("expected FullDuplexCall to be advertised, got %v", sm.Methods())
+	}
+
+	fds := sm.GetFileDescriptors()
+	if len(fds) == 0 || fds[0].GetName() != "grpc/testing/test.proto" {
+		t.Fatalf("expected the file descriptor of the service first, got %d files", len(fds))
+	}
+	if fds[0].GetService()[0].GetName() != "TestService" {
+		t.Errorf("expected TestService in the file descriptor, got %s", fds[0].GetService()[0].GetName())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := sm.Invoke(ctx, grpc_testing.UnimplementedService_UnimplementedCall_FullMethodName, &grpc_testing.Empty{}, &grpc_testing.Empty{})
+	if status.Code(err) != codes.Unimplemented || !strings.Contains(err.Error(), "not implemented by plugin") {
+		t.Errorf("expected a local Unimplemented error, got %v", err)
+	}
+	if _, err := sm.CallRPC(ctx, "UnimplementedCall", &grpc_testing.Empty{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented for a short method name, got %v", err)
+	}
+	if _, err := sm.CallRPC(ctx, "UnaryCall", &grpc_testing.SimpleRequest{}); err != nil {
+		t.Errorf("CallRPC() with a short method name error = %v", err)
+	}
+	if got := impl.runs.Load(); got != 1 {
+		t.Errorf("expected only the advertised call to reach the plugin, got %d calls", got)
+	}
+}
+
+// benchmarkTestService serves a UnaryCall method with the same name as TestService's.
+type benchmarkTestService struct {
+	grpc_testing.UnimplementedBenchmarkServiceServer
+}
+
+func (s *benchmarkTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return &grpc_testing.SimpleResponse{Username: "benchmark"}, nil
+}
+
+// TestTunnelMultipleServices tests that calls are routed by full method name
+// to the services of a plugin, even when method names collide
+func TestTunnelMultipleServices(t *testing.T) {
+	sm := startTunnel(t, &countingTestService{}, stream.WithService(&grpc_testing.BenchmarkService_ServiceDesc, &benchmarkTestService{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := grpc_testing.NewTestServiceClient(sm).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("TestService.UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "run-1" {
+		t.Errorf("expected TestService to answer, got %q", resp.GetUsername())
+	}
+
+	resp, err = grpc_testing.NewBenchmarkServiceClient(sm).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("BenchmarkService.UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "benchmark" {
+		t.Errorf("expected BenchmarkService to answer, got %q", resp.GetUsername())
+	}
+
+	if !slices.Contains(sm.Methods(), grpc_testing.BenchmarkService_StreamingCall_FullMethodName) {
+		t.Errorf("expected BenchmarkService methods to be advertised, got %v", sm.Methods())
+	}
+
+	_, err = stream.NewPluginStreamClient(ctx, newPipeStream(), "duplicate", "v1.0.0", grpc_testing.TestService_ServiceDesc, &countingTestService{},
+		stream.WithService(&grpc_testing.TestService_ServiceDesc, &countingTestService{}))
+	if err == nil || !strings.Contains(err.Error(), "duplicate service registration") {
+		t.Errorf("expected a duplicate service registration error, got %v", err)
+	}
+}
+
+// panickingTestService panics in its handlers, except EmptyCall.
+type panickingTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (s *panickingTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	panic("unary boom")
+}
+
+func (s *panickingTestService) StreamingOutputCall(req *grpc_testing.StreamingOutputCallRequest, ss grpc.ServerStreamingServer[grpc_testing.StreamingOutputCallResponse]) error {
+	panic("stream boom")
+}
+
+func (s *panickingTestService) EmptyCall(ctx context.Context, req *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	return &grpc_testing.Empty{}, nil
+}
+
+// TestTunnelInterceptors tests that plugin-side interceptors run in order around the handlers
+func TestTunnelInterceptors(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			mu.Lock()
+			calls = append(calls, name+" "+info.FullMethod)
+			mu.Unlock()
+			return handler(ctx, req)
+		}
+	}
+	var streams atomic.Int32
+	countStreams := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod == grpc_testing.TestService_StreamingOutputCall_FullMethodName && info.IsServerStream {
+			streams.Add(1)
+		}
+		return handler(srv, ss)
+	}
+	deny := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("x-deny")) > 0 {
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		}
+		return handler(ctx, req)
+	}
+
+	sm := startTunnel(t, &streamingTestService{},
+		stream.WithUnaryInterceptors(record("first"), record("second")),
+		stream.WithUnaryInterceptors(deny),
+		stream.WithStreamInterceptors(countStreams))
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{}); err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	want := []string{
+		"first " + grpc_testing.TestService_UnaryCall_FullMethodName,
+		"second " + grpc_testing.TestService_UnaryCall_FullMethodName,
+	}
+	mu.Lock()
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected interceptors %v, got %v", want, calls)
+	}
+	mu.Unlock()
+
+	_, err := client.UnaryCall(metadata.AppendToOutgoingContext(ctx, "x-deny", "1"), &grpc_testing.SimpleRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied from the interceptor, got %v", err)
+	}
+
+	st, err := client.StreamingOutputCall(ctx, &grpc_testing.StreamingOutputCallRequest{})
+	if err != nil {
+		t.Fatalf("StreamingOutputCall() error = %v", err)
+	}
+	for {
+		if _, err := st.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+	}
+	if got := streams.Load(); got != 1 {
+		t.Errorf("expected the stream interceptor to run once, got %d", got)
+	}
+}
+
+// TestTunnelPanicRecovery tests that a panicking handler fails its call with Internal
+// and does not take the plugin down
+func TestTunnelPanicRecovery(t *testing.T) {
+	sm := startTunnel(t, &panickingTestService{})
+	client := grpc_testing.NewTestServiceClient(sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "unary boom") {
+		t.Errorf("expected Internal with the panic, got %v", err)
+	}
+
+	st, err := client.StreamingOutputCall(ctx, &grpc_testing.StreamingOutputCallRequest{})
+	if err != nil {
+		t.Fatalf("StreamingOutputCall() error = %v", err)
+	}
+	if _, err := st.Recv(); status.Code(err) != codes.Internal || !strings.Contains(err.Error(), "stream boom") {
+		t.Errorf("expected Internal with the panic, got %v", err)
+	}
+
+	// The plugin is still serving
+	if _, err := client.EmptyCall(ctx, &grpc_testing.Empty{}); err != nil {
+		t.Errorf("EmptyCall() after panics error = %v", err)
+	}
+}
+
+// TestTunnelClientInterceptors tests that operator-side interceptors wrap every call,
+// outside of the retry policy
+func TestTunnelClientInterceptors(t *testing.T) {
+	var unaryCalls, streamCalls atomic.Int32
+	var replies sync.Map
+	authorize := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		unaryCalls.Add(1)
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-user", "intercepted", "x-request", method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		replies.Store(fmt.Sprintf("%T", reply), true)
+		return err
+	}
+	countStreams := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamCalls.Add(1)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+
+	sm := startTunnel(t, &metadataTestService{},
+		stream.WithUnaryClientInterceptors(authorize),
+		stream.WithStreamClientInterceptors(countStreams))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var header metadata.MD
+	resp, err := grpc_testing.NewTestServiceClient(sm).UnaryCall(ctx, &grpc_testing.SimpleRequest{}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "intercepted" {
+		t.Errorf("expected the metadata of the interceptor, got username %q", resp.GetUsername())
+	}
+	if got := header.Get("x-echo"); len(got) != 1 || got[0] != grpc_testing.TestService_UnaryCall_FullMethodName {
+		t.Errorf("expected the method in the interceptor, got %v", got)
+	}
+	if _, ok := replies.Load("*grpc_testing.SimpleResponse"); !ok {
+		t.Error("expected the interceptor to see the typed reply of Invoke")
+	}
+
+	payload, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("CallRPC() error = %v", err)
+	}
+	out := &grpc_testing.SimpleResponse{}
+	if err := proto.Unmarshal(payload, out); err != nil || out.GetUsername() != "intercepted" {
+		t.Errorf("expected CallRPC to go through the interceptor, got %q (%v)", out.GetUsername(), err)
+	}
+	if got := unaryCalls.Load(); got != 2 {
+		t.Errorf("expected 2 intercepted unary calls, got %d", got)
+	}
+
+	// Streaming calls go through the stream interceptor; the plugin does not serve this one
+	if _, err := grpc_testing.NewTestServiceClient(sm).FullDuplexCall(ctx); err != nil {
+		t.Fatalf("FullDuplexCall() error = %v", err)
+	}
+	if got := streamCalls.Load(); got != 1 {
+		t.Errorf("expected 1 intercepted stream, got %d", got)
+	}
+
+	t.Run("retries", func(t *testing.T) {
+		var calls atomic.Int32
+		count := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls.Add(1)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		impl := &countingTestService{failures: 2}
+		sm := startTunnel(t, impl,
+			stream.WithUnaryClientInterceptors(count),
+			stream.WithRetryPolicy("", stream.RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: 10 * time.Millisecond,
+				RetryableCodes: []codes.Code{codes.Unavailable},
+			}))
+
+		if _, err := grpc_testing.NewTestServiceClient(sm).UnaryCall(ctx, &grpc_testing.SimpleRequest{}); err != nil {
+			t.Fatalf("UnaryCall() error = %v", err)
+		}
+		if calls.Load() != 1 || impl.runs.Load() != 3 {
+			t.Errorf("expected 1 intercepted call and 3 attempts, got %d and %d", calls.Load(), impl.runs.Load())
+		}
+	})
+}
+
+// nextState returns the next connection state reported to a ConnectionStateHandler.
+func nextState(t *testing.T, states <-chan stream.ConnectionState) stream.ConnectionState {
+	t.Helper()
+
+	select {
+	case state := <-states:
+		return state
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection state did not change")
+		return 0
+	}
+}
+
+// TestTunnelReconnect tests that a plugin losing its stream registers again on a new one
+// and keeps serving calls
+func TestTunnelReconnect(t *testing.T) {
+	svc := &tunnelService{managers: make(chan *stream.StreamManager, 2)}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Only the first stream is cancelled, the dialer opens the next ones
+	streamCtx, dropStream := context.WithCancel(ctx)
+	pluginStream, err := frameworkClient.PluginStream(streamCtx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+
+	states := make(chan stream.ConnectionState, 4)
+	dial := func(ctx context.Context) (stream.StreamInterface, error) {
+		return frameworkClient.PluginStream(ctx)
+	}
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "test-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, &echoTestService{},
+		stream.WithReconnect(dial, stream.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}),
+		stream.WithConnectionStateHandler(func(state stream.ConnectionState, _ error) { states <- state }))
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	handleErr := make(chan error, 1)
+	go func() { handleErr <- psc.HandleRPCCalls(ctx) }()
+
+	first := nextManager(t, svc)
+	firstSession := psc.GetSessionID()
+
+	dropStream()
+	if state := nextState(t, states); state != stream.StateReconnecting {
+		t.Fatalf("expected %v, got %v", stream.StateReconnecting, state)
+	}
+	if state := nextState(t, states); state != stream.StateConnected {
+		t.Fatalf("expected %v, got %v", stream.StateConnected, state)
+	}
+	second := nextManager(t, svc)
+
+	callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer callCancel()
+	if _, err := first.CallRPC(callCtx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{}); !stream.IsPluginUnavailable(err) {
+		t.Errorf("expected Unavailable on the lost stream, got %v", err)
+	}
+	resp, err := grpc_testing.NewTestServiceClient(second).UnaryCall(callCtx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: []byte("again")}})
+	if err != nil {
+		t.Fatalf("UnaryCall() after reconnect error = %v", err)
+	}
+	if string(resp.GetPayload().GetBody()) != "again" {
+		t.Errorf("expected the echoed payload, got %q", resp.GetPayload().GetBody())
+	}
+	if psc.GetSessionID() == firstSession || psc.GetSessionID() != second.GetSessionID() {
+		t.Errorf("expected the session of the new stream %q, got %q", second.GetSessionID(), psc.GetSessionID())
+	}
+
+	cancel()
+	select {
+	case err := <-handleErr:
+		if err != nil {
+			t.Errorf("HandleRPCCalls() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HandleRPCCalls did not return")
+	}
+}
+
+// TestTunnelReconnectGivesUp tests that HandleRPCCalls returns once the plugin could not
+// reconnect within the retry window
+func TestTunnelReconnectGivesUp(t *testing.T) {
+	svc := &tunnelService{managers: make(chan *stream.StreamManager, 1)}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamCtx, dropStream := context.WithCancel(ctx)
+	pluginStream, err := frameworkClient.PluginStream(streamCtx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+
+	var dials atomic.Int32
+	dialErr := status.Error(codes.Unavailable, "operator is down")
+	dial := func(ctx context.Context) (stream.StreamInterface, error) {
+		dials.Add(1)
+		return nil, dialErr
+	}
+	states := make(chan stream.ConnectionState, 4)
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "test-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, &echoTestService{},
+		stream.WithReconnect(dial, stream.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, MaxElapsedTime: 300 * time.Millisecond}),
+		stream.WithConnectionStateHandler(func(state stream.ConnectionState, _ error) { states <- state }))
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	handleErr := make(chan error, 1)
+	go func() { handleErr <- psc.HandleRPCCalls(ctx) }()
+	nextManager(t, svc)
+
+	dropStream()
+	select {
+	case err := <-handleErr:
+		if !errors.Is(err, dialErr) {
+			t.Errorf("expected the last dial error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HandleRPCCalls did not give up")
+	}
+	if dials.Load() < 2 {
+		t.Errorf("expected several reconnection attempts, got %d", dials.Load())
+	}
+	if state := nextState(t, states); state != stream.StateReconnecting {
+		t.Errorf("expected %v, got %v", stream.StateReconnecting, state)
+	}
+	if state := nextState(t, states); state != stream.StateDisconnected {
+		t.Errorf("expected %v, got %v", stream.StateDisconnected, state)
+	}
+}
+
+// TestTunnelSessionResumption tests that a plugin reconnecting within the grace period
+// resumes its session: calls in flight on both sides get their answer
+func TestTunnelSessionResumption(t *testing.T) {
+	svc := &tunnelService{
+		managers: make(chan *stream.StreamManager, 2),
+		opts:     []stream.Option{stream.WithSessionStore(stream.NewSessionStore(10 * time.Second))},
+	}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamCtx, dropStream := context.WithCancel(ctx)
+	pluginStream, err := frameworkClient.PluginStream(streamCtx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+
+	// Reconnections wait for the test
+	allowDial := make(chan struct{})
+	dial := func(ctx context.Context) (stream.StreamInterface, error) {
+		select {
+		case <-allowDial:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return frameworkClient.PluginStream(ctx)
+	}
+	states := make(chan stream.ConnectionState, 4)
+	impl := &gatedTestService{started: make(chan string, 2), release: make(chan struct{})}
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "test-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, impl,
+		stream.WithReconnect(dial, stream.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}),
+		stream.WithConnectionStateHandler(func(state stream.ConnectionState, _ error) { states <- state }))
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	go func() { _ = psc.HandleRPCCalls(ctx) }()
+
+	sm := nextManager(t, svc)
+	if !slices.Contains(psc.GetFeatures(), stream.FeatureSessionResumption) {
+		t.Fatalf("expected session resumption to be accepted, got %v", psc.GetFeatures())
+	}
+	sessionID := psc.GetSessionID()
+
+	callCtx, callCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer callCancel()
+	callErrs := make(chan error, 2)
+	go func() {
+		_, err := sm.CallRPC(callCtx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		callErrs <- err
+	}()
+	<-impl.started
+
+	// The handler answers while the plugin is disconnected, and the operator calls again
+	dropStream()
+	if state := nextState(t, states); state != stream.StateReconnecting {
+		t.Fatalf("expected %v, got %v", stream.StateReconnecting, state)
+	}
+	close(impl.release)
+	go func() {
+		_, err := sm.CallRPC(callCtx, grpc_testing.TestService_EmptyCall_FullMethodName, &grpc_testing.Empty{})
+		callErrs <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	close(allowDial)
+	if state := nextState(t, states); state != stream.StateConnected {
+		t.Fatalf("expected %v, got %v", stream.StateConnected, state)
+	}
+	if resumed := nextManager(t, svc); resumed != sm {
+		t.Error("expected the session to be resumed on the same StreamManager")
+	}
+	if psc.GetSessionID() != sessionID {
+		t.Errorf("expected session %q to be resumed, got %q", sessionID, psc.GetSessionID())
+	}
+
+	for range 2 {
+		if err := <-callErrs; err != nil {
+			t.Errorf("call across the reconnection failed: %v", err)
+		}
+	}
+	if _, err := sm.CallRPC(callCtx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{}); err != nil {
+		t.Errorf("CallRPC() after resumption error = %v", err)
+	}
+}
+
+// TestTunnelSessionExpiry tests that calls pending on a session that is not resumed
+// within the grace period fail
+func TestTunnelSessionExpiry(t *testing.T) {
+	svc := &tunnelService{
+		managers: make(chan *stream.StreamManager, 1),
+		opts:     []stream.Option{stream.WithSessionStore(stream.NewSessionStore(200 * time.Millisecond))},
+	}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	streamCtx, dropStream := context.WithCancel(ctx)
+	pluginStream, err := frameworkClient.PluginStream(streamCtx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+	dial := func(ctx context.Context) (stream.StreamInterface, error) {
+		return nil, status.Error(codes.Unavailable, "operator is down")
+	}
+	impl := &gatedTestService{started: make(chan string, 1), release: make(chan struct{})}
+	defer close(impl.release)
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "test-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, impl,
+		stream.WithReconnect(dial, stream.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	go func() { _ = psc.HandleRPCCalls(ctx) }()
+	sm := nextManager(t, svc)
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := sm.CallRPC(ctx, grpc_testing.TestService_UnaryCall_FullMethodName, &grpc_testing.SimpleRequest{})
+		callErr <- err
+	}()
+	<-impl.started
+
+	start := time.Now()
+	dropStream()
+	select {
+	case err := <-callErr:
+		if !stream.IsPluginUnavailable(err) {
+			t.Errorf("expected Unavailable once the session expired, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("call failed after %v, before the grace period", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending call was not failed when the session expired")
+	}
+}
+
+// callbackTestService is a callback service of the operator, answering with the name
+// of the calling plugin.
+type callbackTestService struct {
+	grpc_testing.UnimplementedBenchmarkServiceServer
+}
+
+func (s *callbackTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	sm, ok := stream.PluginFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "no plugin in the handler context")
+	}
+	return &grpc_testing.SimpleResponse{Username: sm.GetPluginName(), Payload: req.GetPayload()}, nil
+}
+
+func (s *callbackTestService) StreamingCall(ss grpc.BidiStreamingServer[grpc_testing.SimpleRequest, grpc_testing.SimpleResponse]) error {
+	for {
+		req, err := ss.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := ss.Send(&grpc_testing.SimpleResponse{Payload: req.GetPayload()}); err != nil {
+			return err
+		}
+	}
+}
+
+// relayTestService is a plugin service calling the operator back while handling a call.
+type relayTestService struct {
+	grpc_testing.UnimplementedTestServiceServer
+	operator func() grpc.ClientConnInterface
+}
+
+func (s *relayTestService) UnaryCall(ctx context.Context, req *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	return grpc_testing.NewBenchmarkServiceClient(s.operator()).UnaryCall(ctx, req)
+}
+
+// TestTunnelCallbackServices tests that plugins call the callback services of the operator
+// over their stream, including while handling a call of the operator
+func TestTunnelCallbackServices(t *testing.T) {
+	svc := &tunnelService{
+		managers: make(chan *stream.StreamManager, 1),
+		opts:     []stream.Option{stream.WithCallbackService(&grpc_testing.BenchmarkService_ServiceDesc, &callbackTestService{})},
+	}
+	frameworkClient := serveTunnel(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pluginStream, err := frameworkClient.PluginStream(ctx)
+	if err != nil {
+		t.Fatalf("failed to open plugin stream: %v", err)
+	}
+	relay := &relayTestService{}
+	psc, err := stream.NewPluginStreamClient(ctx, pluginStream, "callback-plugin", "v1.0.0", grpc_testing.TestService_ServiceDesc, relay)
+	if err != nil {
+		t.Fatalf("failed to create plugin stream client: %v", err)
+	}
+	relay.operator = psc.OperatorConn
+	go func() { _ = psc.HandleRPCCalls(ctx) }()
+	sm := nextManager(t, svc)
+
+	if !slices.Contains(psc.GetFeatures(), stream.FeatureOperatorServices) {
+		t.Fatalf("expected operator services to be accepted, got %v", psc.GetFeatures())
+	}
+	operator := grpc_testing.NewBenchmarkServiceClient(psc.OperatorConn())
+
+	resp, err := operator.UnaryCall(ctx, &grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: []byte("status")}})
+	if err != nil {
+		t.Fatalf("UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "callback-plugin" || string(resp.GetPayload().GetBody()) != "status" {
+		t.Errorf("expected the callback to identify callback-plugin, got %v", resp)
+	}
+
+	// The plugin calls back the operator while handling its call, on the same stream
+	resp, err = grpc_testing.NewTestServiceClient(sm).UnaryCall(ctx, &grpc_testing.SimpleRequest{})
+	if err != nil {
+		t.Fatalf("relayed UnaryCall() error = %v", err)
+	}
+	if resp.GetUsername() != "callback-plugin" {
+		t.Errorf("expected the relayed call to reach the operator, got %q", resp.GetUsername())
+	}
+
+	// Streaming calls are multiplexed with the calls of the operator
+	bidi, err := operator.StreamingCall(ctx)
+	if err != nil {
+		t.Fatalf("StreamingCall() error = %v", err)
+	}
+	for i := range 20 {
+		body := []byte(fmt.Sprintf("event-%d", i))
+		if err := bidi.Send(&grpc_testing.SimpleRequest{Payload: &grpc_testing.Payload{Body: body}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		resp, err := bidi.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		if !bytes.Equal(resp.GetPayload().GetBody(), body) {
+			t.Errorf("expected %q, got %q", body, resp.GetPayload().GetBody())
+		}
+	}
+	if err := bidi.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error = %v", err)
+	}
+	if _, err := bidi.Recv(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the call, got %v", err)
+	}
+
+	// Methods the operator does not serve are rejected without being sent
+	_, err = grpc_testing.NewTestServiceClient(psc.OperatorConn()).EmptyCall(ctx, &grpc_testing.Empty{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Unimplemented for a method not served, got %v", err)
+	}
+}